@@ -0,0 +1,401 @@
+package gointervaltree
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// sortedIntervals method returns all intervals maintained in the tree sorted by start and then by end.
+func (tree *intervalTree[T, D]) sortedIntervals() []Interval[T, D] {
+	result := tree.Iter()
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].start != result[j].start {
+			return result[i].start < result[j].start
+		}
+		return result[i].end < result[j].end
+	})
+	return result
+}
+
+// ApplyMask method returns every interval maintained in the tree with all mask ranges subtracted from it,
+// splitting intervals where a mask falls inside them and dropping intervals covered by masks entirely.
+// Pieces keep the data of their original interval and are returned sorted by start.
+// Masks whose start is not numerically less than their end are ignored.
+func (tree *intervalTree[T, D]) ApplyMask(masks []Interval[T, D]) []Interval[T, D] {
+	sortedMasks := make([]Interval[T, D], 0, len(masks))
+	for _, mask := range masks {
+		if mask.start < mask.end {
+			sortedMasks = append(sortedMasks, mask)
+		}
+	}
+	sort.Slice(sortedMasks, func(i, j int) bool {
+		return sortedMasks[i].start < sortedMasks[j].start
+	})
+	var result []Interval[T, D]
+	for _, element := range tree.sortedIntervals() {
+		cursor := element.start
+		for _, mask := range sortedMasks {
+			if mask.start >= element.end {
+				break
+			}
+			if mask.end <= cursor {
+				continue
+			}
+			if mask.start > cursor {
+				result = append(result, Interval[T, D]{start: cursor, end: mask.start, data: element.data})
+			}
+			cursor = mask.end
+			if cursor >= element.end {
+				break
+			}
+		}
+		if cursor < element.end {
+			result = append(result, Interval[T, D]{start: cursor, end: element.end, data: element.data})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].start < result[j].start
+	})
+	return result
+}
+
+// Filter method returns a new, independent tree with the same bounds and options containing only the intervals
+// for which pred returns true. The returned tree is sorted and ready to be queried, the original tree is not changed.
+func (tree *intervalTree[T, D]) Filter(pred func(start, end T, data D) bool) *IntervalTree[T, D] {
+	// bounds of an existing tree are valid, so derive cannot fail
+	filtered, _ := tree.derive(tree.min, tree.max)
+	for _, element := range tree.Iter() {
+		if pred(element.start, element.end, element.data) {
+			filtered.insert(element.start, element.end, element.data)
+		}
+	}
+	filtered.Sort()
+	return filtered
+}
+
+// OverlapMatrix method returns, for every interval of the tree overlapping at least one interval of other,
+// the intervals of other it overlaps. Keys are indices of intervals of the tree in the order of sorting by start
+// and then by end, values are sorted the same way. Intervals of other are looked up with a range query per key.
+func (tree *intervalTree[T, D]) OverlapMatrix(other *IntervalTree[T, D]) map[int][]Interval[T, D] {
+	result := make(map[int][]Interval[T, D])
+	for index, element := range tree.sortedIntervals() {
+		var overlapping []Interval[T, D]
+		other.visitRange(element.start, element.end, func(i *interval[T, D]) bool {
+			overlapping = append(overlapping, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+			return true
+		})
+		if len(overlapping) == 0 {
+			continue
+		}
+		sort.SliceStable(overlapping, func(i, j int) bool {
+			if overlapping[i].start != overlapping[j].start {
+				return overlapping[i].start < overlapping[j].start
+			}
+			return overlapping[i].end < overlapping[j].end
+		})
+		result[index] = overlapping
+	}
+	return result
+}
+
+// StabbingPoints method returns a minimal set of coordinates in ascending order such that every interval maintained
+// in the tree contains at least one of them. It uses the greedy algorithm: intervals are processed by ascending end
+// and the last coordinate of every interval not yet stabbed is taken, which is end for closed trees and end-1
+// otherwise. For floating-point coordinates of half-open intervals, which have no last coordinate, the largest start
+// among the intervals stabbed together is taken instead.
+func (tree *intervalTree[T, D]) StabbingPoints() []T {
+	intervals := tree.Iter()
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].end < intervals[j].end
+	})
+	var result []T
+	var stabbedBefore T
+	for _, element := range intervals {
+		if n := len(result); n > 0 && !tree.endsBefore(stabbedBefore, element.start) {
+			if isFloat[T]() && !tree.options.closed {
+				result[n-1] = max(result[n-1], element.start)
+			}
+			continue
+		}
+		stabbedBefore = element.end
+		switch {
+		case tree.options.closed:
+			result = append(result, element.end)
+		case isFloat[T]():
+			result = append(result, element.start)
+		default:
+			result = append(result, element.end-1)
+		}
+	}
+	return result
+}
+
+// MaxNonOverlapping method returns a largest subset of pairwise non-overlapping intervals maintained in the tree
+// sorted by start. It uses the greedy earliest-end-first algorithm, intervals touching at their ends do not overlap.
+func (tree *intervalTree[T, D]) MaxNonOverlapping() []Interval[T, D] {
+	intervals := tree.Iter()
+	sort.SliceStable(intervals, func(i, j int) bool {
+		return intervals[i].end < intervals[j].end
+	})
+	var result []Interval[T, D]
+	for _, element := range intervals {
+		if n := len(result); n > 0 && element.start < result[n-1].end {
+			continue
+		}
+		result = append(result, element)
+	}
+	return result
+}
+
+// Diff method compares the tree with a newer version of it and returns intervals present only in newer (added)
+// and intervals present only in the tree (removed), both sorted by start and then by end. Intervals are matched by
+// start, end and data equality reported by eq, using a merge of both sorted interval sets.
+func (tree *intervalTree[T, D]) Diff(newer *IntervalTree[T, D], eq func(a, b D) bool) (added, removed []Interval[T, D]) {
+	before, after := tree.sortedIntervals(), newer.sortedIntervals()
+	less := func(a, b Interval[T, D]) bool {
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		return a.end < b.end
+	}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		if j == len(after) || (i < len(before) && less(before[i], after[j])) {
+			removed = append(removed, before[i])
+			i++
+			continue
+		}
+		if i == len(before) || less(after[j], before[i]) {
+			added = append(added, after[j])
+			j++
+			continue
+		}
+		// both sides hold a group of intervals with identical bounds, match them by data
+		groupEnd := i
+		for groupEnd < len(before) && !less(before[i], before[groupEnd]) {
+			groupEnd++
+		}
+		var unmatched []Interval[T, D]
+		for ; j < len(after) && !less(before[i], after[j]); j++ {
+			unmatched = append(unmatched, after[j])
+		}
+		for ; i < groupEnd; i++ {
+			matched := false
+			for k, candidate := range unmatched {
+				if eq(before[i].data, candidate.data) {
+					unmatched = append(unmatched[:k], unmatched[k+1:]...)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				removed = append(removed, before[i])
+			}
+		}
+		added = append(added, unmatched...)
+	}
+	return added, removed
+}
+
+// Equal method reports whether the tree and other hold the same intervals regardless of tree structure and insertion
+// order. Intervals are compared by start, end and data equality reported by dataEq, duplicates must occur equally
+// often in both trees. Bounds of the trees are not compared.
+func (tree *intervalTree[T, D]) Equal(other *IntervalTree[T, D], dataEq func(a, b D) bool) bool {
+	if tree.Len() != other.Len() {
+		return false
+	}
+	added, removed := tree.Diff(other, dataEq)
+	return len(added) == 0 && len(removed) == 0
+}
+
+// IterStableBy method returns all intervals maintained in the tree ordered by primary, breaking ties with secondary.
+// Comparators return a negative number, zero or a positive number like cmp.Compare. The sort is stable, so intervals
+// equal under both comparators keep their Iter order and the output is deterministic.
+func (tree *intervalTree[T, D]) IterStableBy(primary, secondary func(a, b Interval[T, D]) int) []Interval[T, D] {
+	result := tree.Iter()
+	sort.SliceStable(result, func(i, j int) bool {
+		if c := primary(result[i], result[j]); c != 0 {
+			return c < 0
+		}
+		return secondary(result[i], result[j]) < 0
+	})
+	return result
+}
+
+// OverlappingPairs method returns every unordered pair of intervals maintained in the tree which overlap each other,
+// each pair once with A starting no later than B. It sweeps intervals sorted by start while maintaining the set of
+// active intervals, so the work is proportional to the number of intervals and reported pairs.
+func (tree *intervalTree[T, D]) OverlappingPairs() []struct{ A, B Interval[T, D] } {
+	var result []struct{ A, B Interval[T, D] }
+	var active []Interval[T, D]
+	for _, element := range tree.sortedIntervals() {
+		kept := active[:0]
+		for _, a := range active {
+			if a.end > element.start {
+				kept = append(kept, a)
+			}
+		}
+		active = kept
+		for _, a := range active {
+			result = append(result, struct{ A, B Interval[T, D] }{a, element})
+		}
+		active = append(active, element)
+	}
+	return result
+}
+
+// MergeTrees creates a sorted tree holding the intervals of all given trees together with their data.
+// The bounds of the new tree span the bounds of all inputs, nil trees are skipped, and its options are those of
+// the first non-nil tree. Intervals are collected once and sorted in a single pass instead of merging the trees
+// pairwise. An interval the new tree cannot hold, e.g. a single-point interval of a WithClosedIntervals tree merged
+// into a half-open one, yields an error.
+func MergeTrees[T Coordinate, D any](trees []*IntervalTree[T, D]) (*IntervalTree[T, D], error) {
+	var lower, upper T
+	var first *IntervalTree[T, D]
+	found := false
+	for _, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		if first == nil {
+			first = tree
+		}
+		if !found || tree.min < lower {
+			lower = tree.min
+		}
+		if !found || tree.max > upper {
+			upper = tree.max
+		}
+		found = true
+	}
+	if !found {
+		return nil, errors.New("at least one tree is required to merge")
+	}
+	merged, err := first.derive(lower, upper)
+	if err != nil {
+		return nil, err
+	}
+	for index, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		for _, element := range tree.Iter() {
+			if err = merged.AddInterval(element.start, element.end, element.data); err != nil {
+				return nil, fmt.Errorf("tree %d: %w", index, err)
+			}
+		}
+	}
+	merged.Sort()
+	return merged, nil
+}
+
+// Merge method creates a sorted tree holding the intervals of both the tree and other, with bounds spanning the
+// bounds of both, see MergeTrees. Neither input is modified.
+func (tree *intervalTree[T, D]) Merge(other *IntervalTree[T, D]) (*IntervalTree[T, D], error) {
+	if other == nil {
+		return nil, errors.New("tree to merge with must not be nil")
+	}
+	return MergeTrees([]*intervalTree[T, D]{tree, other})
+}
+
+// LongestChain method returns the intervals of the longest chain of overlapping intervals sorted by start, where
+// a chain is a sequence of intervals in which each interval overlaps the next one and ends after it, and its length
+// is the extent it covers from the start of the first interval to the end of the last one. Intervals merely
+// touching at their ends do not overlap, and intervals nested in a chain member do not extend the chain, so they are
+// not part of it. Ties are broken by the number of intervals in a chain and then by the earliest start.
+func (tree *intervalTree[T, D]) LongestChain() []Interval[T, D] {
+	var best, component []Interval[T, D]
+	var bestEnd, componentEnd T
+	for _, element := range tree.sortedIntervals() {
+		if len(component) > 0 && element.start < componentEnd {
+			component = append(component, element)
+			componentEnd = max(componentEnd, element.end)
+			continue
+		}
+		if len(component) > 0 {
+			best, bestEnd = longerChain(best, bestEnd, chainOf(component), componentEnd)
+		}
+		component, componentEnd = []Interval[T, D]{element}, element.end
+	}
+	if len(component) > 0 {
+		best, _ = longerChain(best, bestEnd, chainOf(component), componentEnd)
+	}
+	return best
+}
+
+// chainOf returns a chain spanning a group of intervals sorted by start and then by end and connected through
+// pairwise overlaps. Starting from the longest interval with the smallest start, it repeatedly appends the
+// interval reaching furthest among those overlapping the last one, which reaches the end of the group.
+func chainOf[T Coordinate, D any](group []Interval[T, D]) []Interval[T, D] {
+	k := 0
+	for k+1 < len(group) && group[k+1].start == group[0].start {
+		k++
+	}
+	chain := []Interval[T, D]{group[k]}
+	for k++; k < len(group); {
+		last := chain[len(chain)-1]
+		next := -1
+		for ; k < len(group) && group[k].start < last.end; k++ {
+			if group[k].end > last.end && (next < 0 || group[k].end > group[next].end) {
+				next = k
+			}
+		}
+		if next < 0 {
+			break
+		}
+		chain = append(chain, group[next])
+	}
+	return chain
+}
+
+// longerChain returns the longer of two chains given as intervals sorted by start together with their ends,
+// preferring the first one on ties.
+func longerChain[T Coordinate, D any](a []Interval[T, D], aEnd T, b []Interval[T, D], bEnd T) ([]Interval[T, D], T) {
+	if len(a) == 0 {
+		return b, bEnd
+	}
+	aLength, bLength := aEnd-a[0].start, bEnd-b[0].start
+	if bLength > aLength || (bLength == aLength && len(b) > len(a)) {
+		return b, bEnd
+	}
+	return a, aEnd
+}
+
+// FirstSpacingViolation method scans intervals sorted by start and then by end and returns the first adjacent pair
+// whose gap, the start of the second minus the end of the first, is less than minGap. Overlapping intervals have
+// a negative gap, which for unsigned coordinates violates any minGap. ok is false if all adjacent pairs satisfy
+// the spacing.
+func (tree *intervalTree[T, D]) FirstSpacingViolation(minGap T) (a, b Interval[T, D], ok bool) {
+	intervals := tree.sortedIntervals()
+	for k := 1; k < len(intervals); k++ {
+		overlapping := intervals[k].start < intervals[k-1].end
+		if (overlapping && minGap >= 0) || intervals[k].start-intervals[k-1].end < minGap {
+			return intervals[k-1], intervals[k], true
+		}
+	}
+	return a, b, false
+}
+
+// Coalesce method returns a new sorted tree with the same bounds and options in which every group of overlapping or
+// adjacent intervals is replaced by a single interval spanning the group. Data of a group is folded with merge in
+// order of start and then of end, i.e. merge(merge(a, b), c), a single interval keeps its data.
+func (tree *intervalTree[T, D]) Coalesce(merge func(a, b D) D) *IntervalTree[T, D] {
+	// bounds of an existing tree are valid, so derive cannot fail
+	coalesced, _ := tree.derive(tree.min, tree.max)
+	var groups []Interval[T, D]
+	for _, element := range tree.sortedIntervals() {
+		if n := len(groups); n > 0 && element.start <= groups[n-1].end {
+			groups[n-1].end = max(groups[n-1].end, element.end)
+			groups[n-1].data = merge(groups[n-1].data, element.data)
+			continue
+		}
+		groups = append(groups, element)
+	}
+	// groups span intervals of the tree, so they are valid intervals of a tree with the same options
+	for _, group := range groups {
+		coalesced.insert(group.start, group.end, group.data)
+	}
+	coalesced.Sort()
+	return coalesced
+}
@@ -0,0 +1,397 @@
+package gointervaltree
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// Tests
+
+func TestIntervalTree_ApplyMask(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 40, "a")
+	_ = tree.AddInterval(50, 60, "b")
+	_ = tree.AddInterval(70, 80, "c")
+	tree.Sort()
+	masks := []Interval[int, any]{NewInterval[int, any](30, 35, nil), NewInterval[int, any](15, 20, nil), NewInterval[int, any](45, 65, nil), NewInterval[int, any](75, 76, nil)}
+	assert.Equal(t, []Interval[int, any]{
+		{10, 15, "a"}, {20, 30, "a"}, {35, 40, "a"}, {70, 75, "c"}, {76, 80, "c"},
+	}, tree.ApplyMask(masks))
+}
+
+func TestIntervalTree_ApplyMaskNoMasks(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 40, "a")
+	assert.Equal(t, []Interval[int, any]{{10, 40, "a"}}, tree.ApplyMask(nil))
+	assert.Equal(t, []Interval[int, any]{{10, 40, "a"}}, tree.ApplyMask([]Interval[int, any]{NewInterval[int, any](30, 20, nil)}))
+}
+
+func TestInterval_Accessors(t *testing.T) {
+	i := NewInterval(1, 5, "x")
+	assert.Equal(t, 1, i.Start())
+	assert.Equal(t, 5, i.End())
+	assert.Equal(t, "x", i.Data())
+}
+
+func TestIntervalTree_Filter(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, "gene")
+	_ = tree.AddInterval(15, 25, "exon")
+	_ = tree.AddInterval(40, 60, "gene")
+	_ = tree.AddInterval(45, 50, "exon")
+	tree.Sort()
+	original := tree.Iter()
+	genes := tree.Filter(func(start, end int, data any) bool {
+		return data == "gene"
+	})
+	assert.Equal(t, 0, genes.min)
+	assert.Equal(t, 100, genes.max)
+	assert.ElementsMatch(t, []Interval[int, any]{{10, 20, "gene"}, {40, 60, "gene"}}, genes.Iter())
+	assert.Equal(t, []Interval[int, any]{{40, 60, "gene"}}, genes.Query(47))
+	assert.Equal(t, original, tree.Iter())
+	assert.Equal(t, 0, tree.Filter(func(start, end int, data any) bool { return false }).Len())
+}
+
+func TestIntervalTree_OverlapMatrix(t *testing.T) {
+	a, _ := NewIntervalTree(0, 100)
+	_ = a.AddInterval(50, 70, "a2")
+	_ = a.AddInterval(10, 30, "a0")
+	_ = a.AddInterval(35, 40, "a1")
+	_ = a.AddInterval(80, 90, "a3")
+	a.Sort()
+	b, _ := NewIntervalTree(0, 100)
+	_ = b.AddInterval(25, 35, "b0")
+	_ = b.AddInterval(5, 12, "b1")
+	_ = b.AddInterval(60, 95, "b2")
+	_ = b.AddInterval(40, 50, "b3")
+	b.Sort()
+	assert.Equal(t, map[int][]Interval[int, any]{
+		0: {{5, 12, "b1"}, {25, 35, "b0"}},
+		2: {{60, 95, "b2"}},
+		3: {{60, 95, "b2"}},
+	}, a.OverlapMatrix(b))
+}
+
+func TestIntervalTree_OverlapMatrixEmpty(t *testing.T) {
+	a, _ := NewIntervalTree(0, 100)
+	_ = a.AddInterval(10, 30, nil)
+	b, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, a.OverlapMatrix(b))
+	assert.Empty(t, b.OverlapMatrix(a))
+}
+
+func TestIntervalTree_StabbingPoints(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.StabbingPoints())
+	_ = tree.AddInterval(1, 4, nil)
+	_ = tree.AddInterval(2, 6, nil)
+	_ = tree.AddInterval(5, 8, nil)
+	tree.Sort()
+	assert.Equal(t, []int{3, 7}, tree.StabbingPoints())
+	for _, i := range [][]int{{10, 20}, {12, 14}, {13, 30}, {25, 26}, {40, 50}, {41, 42}, {49, 60}, {70, 71}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	points := tree.StabbingPoints()
+	for _, element := range tree.Iter() {
+		stabbed := false
+		for _, p := range points {
+			if element.start <= p && p < element.end {
+				stabbed = true
+			}
+		}
+		assert.True(t, stabbed, "interval %v is not stabbed", element)
+	}
+	// {1,4}, {5,8}, {12,14}, {25,26}, {41,42}, {49,60}, {70,71} are pairwise disjoint, so seven points are required
+	assert.Len(t, points, 7)
+
+	closed, _ := NewIntervalTreeWithOptions(0, 100, WithClosedIntervals())
+	_ = closed.AddInterval(5, 5, nil)
+	_ = closed.AddInterval(1, 4, nil)
+	_ = closed.AddInterval(4, 6, nil)
+	_ = closed.AddInterval(6, 8, nil)
+	closed.Sort()
+	assert.Equal(t, []int{4, 5, 8}, closed.StabbingPoints())
+	closedFloat, _ := NewIntervalTreeWithOptions(0.0, 10.0, WithClosedIntervals())
+	_ = closedFloat.AddInterval(0.5, 1.5, nil)
+	_ = closedFloat.AddInterval(1.5, 2.5, nil)
+	closedFloat.Sort()
+	assert.Equal(t, []float64{1.5}, closedFloat.StabbingPoints())
+}
+
+func TestIntervalTree_MaxNonOverlapping(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.MaxNonOverlapping())
+	// choosing by earliest start would take {0,10} only
+	_ = tree.AddInterval(0, 10, "long")
+	_ = tree.AddInterval(1, 3, "a")
+	_ = tree.AddInterval(2, 5, nil)
+	_ = tree.AddInterval(4, 6, "b")
+	_ = tree.AddInterval(6, 9, "c")
+	_ = tree.AddInterval(8, 12, nil)
+	tree.Sort()
+	result := tree.MaxNonOverlapping()
+	assert.Equal(t, []Interval[int, any]{{1, 3, "a"}, {4, 6, "b"}, {6, 9, "c"}}, result)
+	for i := 1; i < len(result); i++ {
+		assert.LessOrEqual(t, result[i-1].end, result[i].start)
+	}
+}
+
+func TestIntervalTree_Diff(t *testing.T) {
+	eq := func(a, b any) bool {
+		return a == b
+	}
+	older, _ := NewIntervalTree(0, 100)
+	newer, _ := NewIntervalTree(0, 100)
+	for _, i := range [][]int{{10, 20}, {20, 30}, {45, 55}, {58, 59}} {
+		_ = older.AddInterval(i[0], i[1], "x")
+		_ = newer.AddInterval(i[0], i[1], "x")
+	}
+	_ = older.AddInterval(30, 40, "removed")
+	_ = older.AddInterval(45, 55, "y")
+	_ = newer.AddInterval(45, 55, "z")
+	_ = newer.AddInterval(70, 80, "added")
+	older.Sort()
+	newer.Sort()
+	added, removed := older.Diff(newer, eq)
+	assert.Equal(t, []Interval[int, any]{{45, 55, "z"}, {70, 80, "added"}}, added)
+	assert.Equal(t, []Interval[int, any]{{30, 40, "removed"}, {45, 55, "y"}}, removed)
+	added, removed = older.Diff(older, eq)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+}
+
+func TestIntervalTree_IterStableBy(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, "c")
+	_ = tree.AddInterval(40, 50, "a")
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(10, 20, "b")
+	_ = tree.AddInterval(5, 20, "z")
+	tree.Sort()
+	byBounds := func(a, b Interval[int, any]) int {
+		if c := cmp.Compare(a.start, b.start); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.end, b.end)
+	}
+	byData := func(a, b Interval[int, any]) int {
+		return cmp.Compare(a.data.(string), b.data.(string))
+	}
+	assert.Equal(t, []Interval[int, any]{
+		{5, 20, "z"}, {10, 20, "a"}, {10, 20, "b"}, {10, 20, "c"}, {40, 50, "a"},
+	}, tree.IterStableBy(byBounds, byData))
+	assert.Equal(t, []Interval[int, any]{
+		{10, 20, "a"}, {40, 50, "a"}, {10, 20, "b"}, {10, 20, "c"}, {5, 20, "z"},
+	}, tree.IterStableBy(byData, byBounds))
+}
+
+func TestIntervalTree_OverlappingPairs(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 30, "a")
+	_ = tree.AddInterval(15, 25, "b")
+	_ = tree.AddInterval(20, 40, "c")
+	_ = tree.AddInterval(40, 50, "d")
+	_ = tree.AddInterval(60, 70, "e")
+	tree.Sort()
+	pairs := tree.OverlappingPairs()
+	assert.Len(t, pairs, 3)
+	var names []string
+	for _, p := range pairs {
+		names = append(names, p.A.data.(string)+p.B.data.(string))
+	}
+	assert.Equal(t, []string{"ab", "ac", "bc"}, names)
+
+	disjoint, _ := NewIntervalTree(0, 100)
+	_ = disjoint.AddInterval(10, 20, nil)
+	_ = disjoint.AddInterval(20, 30, nil)
+	_ = disjoint.AddInterval(50, 60, nil)
+	assert.Empty(t, disjoint.OverlappingPairs())
+}
+
+func TestMergeTrees(t *testing.T) {
+	first, _ := NewIntervalTree(0, 100)
+	_ = first.AddInterval(10, 20, "first")
+	_ = first.AddInterval(15, 25, "first")
+	second, _ := NewIntervalTree(100, 200)
+	_ = second.AddInterval(150, 160, "second")
+	third, _ := NewIntervalTree(-100, 0)
+	_ = third.AddInterval(-50, -40, "third")
+	_ = third.AddInterval(-45, 5, "third")
+	merged, err := MergeTrees([]*intervalTree[int, any]{first, nil, second, third})
+	assert.NoError(t, err)
+	assert.Equal(t, -100, merged.min)
+	assert.Equal(t, 200, merged.max)
+	assert.Equal(t, 5, merged.Len())
+	assert.Equal(t, []Interval[int, any]{{10, 20, "first"}}, merged.Query(12))
+	assert.Equal(t, []Interval[int, any]{{150, 160, "second"}}, merged.Query(155))
+	assert.ElementsMatch(t, []Interval[int, any]{{-50, -40, "third"}, {-45, 5, "third"}}, merged.Query(-42))
+	assert.Equal(t, 2, first.Len())
+
+	_, err = MergeTrees([]*intervalTree[int, any]{nil})
+	assert.EqualError(t, err, "at least one tree is required to merge")
+}
+
+func TestIntervalTree_LongestChain(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.LongestChain())
+	_ = tree.AddInterval(60, 75, "p1")
+	_ = tree.AddInterval(70, 80, "p2")
+	_ = tree.AddInterval(10, 20, "c1")
+	_ = tree.AddInterval(18, 25, "c2")
+	_ = tree.AddInterval(24, 30, "c3")
+	_ = tree.AddInterval(29, 35, "c4")
+	_ = tree.AddInterval(35, 40, "touching")
+	tree.Sort()
+	assert.Equal(t, []Interval[int, any]{{10, 20, "c1"}, {18, 25, "c2"}, {24, 30, "c3"}, {29, 35, "c4"}}, tree.LongestChain())
+	_ = tree.AddInterval(50, 78, "p0")
+	tree.Sort()
+	assert.Equal(t, []Interval[int, any]{{50, 78, "p0"}, {70, 80, "p2"}}, tree.LongestChain())
+
+	nested, _ := NewIntervalTree(0, 100)
+	_ = nested.AddInterval(0, 10, "outer")
+	_ = nested.AddInterval(1, 2, "inner")
+	_ = nested.AddInterval(5, 8, "inner")
+	nested.Sort()
+	assert.Equal(t, []Interval[int, any]{{0, 10, "outer"}}, nested.LongestChain())
+	_ = nested.AddInterval(7, 15, "tail")
+	_ = nested.AddInterval(0, 3, "head")
+	nested.Sort()
+	chain := nested.LongestChain()
+	assert.Equal(t, []Interval[int, any]{{0, 10, "outer"}, {7, 15, "tail"}}, chain)
+	for k := 1; k < len(chain); k++ {
+		assert.True(t, chain[k].start < chain[k-1].end && chain[k-1].end < chain[k].end)
+	}
+}
+
+func TestIntervalTree_FirstSpacingViolation(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_, _, ok := tree.FirstSpacingViolation(5)
+	assert.False(t, ok)
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(30, 40, "b")
+	_ = tree.AddInterval(50, 55, "c")
+	tree.Sort()
+	_, _, ok = tree.FirstSpacingViolation(10)
+	assert.False(t, ok)
+	a, b, ok := tree.FirstSpacingViolation(11)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int, any]{10, 20, "a"}, a)
+	assert.Equal(t, Interval[int, any]{30, 40, "b"}, b)
+	_ = tree.AddInterval(58, 70, "d")
+	tree.Sort()
+	a, b, ok = tree.FirstSpacingViolation(5)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int, any]{50, 55, "c"}, a)
+	assert.Equal(t, Interval[int, any]{58, 70, "d"}, b)
+}
+
+func TestIntervalTree_Equal(t *testing.T) {
+	intervals := [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 55}, {46, 57}}
+	first, _ := NewIntervalTree(0, 100)
+	second, _ := NewIntervalTree(0, 200)
+	for k := range intervals {
+		_ = first.AddInterval(intervals[k][0], intervals[k][1], k%3)
+		j := len(intervals) - 1 - k
+		_ = second.AddInterval(intervals[j][0], intervals[j][1], j%3)
+	}
+	first.Sort()
+	second.Sort()
+	eq := func(a, b any) bool { return a == b }
+	empty, _ := NewIntervalTree(0, 100)
+	assert.True(t, empty.Equal(empty, eq))
+	assert.True(t, first.Equal(second, eq))
+	assert.True(t, second.Equal(first, eq))
+	assert.False(t, first.Equal(empty, eq))
+
+	_ = second.AddInterval(50, 60, 0)
+	assert.False(t, first.Equal(second, eq))
+	removed, _ := second.RemoveInterval(50, 60, 0)
+	assert.True(t, removed)
+	removed, _ = second.RemoveInterval(45, 55, 1)
+	assert.True(t, removed)
+	_ = second.AddInterval(45, 55, 2)
+	assert.False(t, first.Equal(second, eq))
+	assert.True(t, first.Equal(second, func(a, b any) bool { return true }))
+}
+
+func TestIntervalTree_Merge(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, bounds := range [][4]int{{0, 600, 400, 1000}, {0, 400, 600, 1000}, {100, 900, 0, 1000}} {
+		first, _ := NewIntervalTree(bounds[0], bounds[1])
+		second, _ := NewIntervalTree(bounds[2], bounds[3])
+		for i := 0; i < 100; i++ {
+			start := bounds[0] + random.Intn(bounds[1]-bounds[0]-1)
+			_ = first.AddInterval(start, start+1+random.Intn(bounds[1]-start), i)
+			start = bounds[2] + random.Intn(bounds[3]-bounds[2]-1)
+			_ = second.AddInterval(start, start+1+random.Intn(bounds[3]-start), -i)
+		}
+		first.Sort()
+		second.Sort()
+		merged, err := first.Merge(second)
+		assert.NoError(t, err)
+		assert.Equal(t, min(bounds[0], bounds[2]), merged.min)
+		assert.Equal(t, max(bounds[1], bounds[3]), merged.max)
+		assert.Equal(t, 200, merged.Len())
+		assert.Equal(t, 100, first.Len())
+		for x := -1; x <= 1001; x += 3 {
+			expected := append(first.Query(x), second.Query(x)...)
+			assert.ElementsMatch(t, expected, merged.Query(x), "point %d", x)
+		}
+	}
+	tree, _ := NewIntervalTree(0, 100)
+	_, err := tree.Merge(nil)
+	assert.EqualError(t, err, "tree to merge with must not be nil")
+}
+
+func TestIntervalTree_Coalesce(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	join := func(a, b any) any { return a.(string) + b.(string) }
+	assert.Equal(t, 0, tree.Coalesce(join).Len())
+	_ = tree.AddInterval(5, 15, "b")
+	_ = tree.AddInterval(0, 10, "a")
+	_ = tree.AddInterval(15, 20, "c")
+	_ = tree.AddInterval(18, 19, "d")
+	_ = tree.AddInterval(30, 40, "e")
+	_ = tree.AddInterval(41, 50, "f")
+	_ = tree.AddInterval(60, 70, "g")
+	_ = tree.AddInterval(55, 80, "h")
+	tree.Sort()
+	coalesced := tree.Coalesce(join)
+	assert.Equal(t, []Interval[int, any]{{0, 20, "abcd"}, {30, 40, "e"}, {41, 50, "f"}, {55, 80, "hg"}}, coalesced.sortedIntervals())
+	assert.Equal(t, []Interval[int, any]{{0, 20, "abcd"}}, coalesced.Query(12))
+	assert.Empty(t, coalesced.Query(40))
+	assert.Equal(t, 8, tree.Len())
+}
+
+func TestIntervalTree_DerivedTreesClosedIntervals(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithClosedIntervals())
+	_ = tree.AddInterval(5, 5, "point")
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(20, 30, "b")
+	tree.Sort()
+
+	filtered := tree.Filter(func(start, end int, data any) bool { return true })
+	assert.Equal(t, 3, filtered.Len())
+	assert.ElementsMatch(t, tree.Query(20), filtered.Query(20))
+	assert.Equal(t, []Interval[int, any]{{5, 5, "point"}}, filtered.Query(5))
+
+	coalesced := tree.Coalesce(func(a, b any) any { return fmt.Sprint(a, "+", b) })
+	assert.Equal(t, []Interval[int, any]{{5, 5, "point"}, {10, 30, "a+b"}}, coalesced.IterSorted())
+	assert.Len(t, coalesced.Query(30), 1)
+
+	other, _ := NewIntervalTreeWithOptions(50, 200, WithClosedIntervals())
+	_ = other.AddInterval(150, 160, "c")
+	merged, err := tree.Merge(other)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, merged.Len())
+	assert.Len(t, merged.Query(160), 1)
+	assert.Len(t, merged.Query(5), 1)
+
+	halfOpen, _ := NewIntervalTree(0, 100)
+	_, err = MergeTrees([]*IntervalTree[int, any]{halfOpen, tree})
+	assert.EqualError(t, err, "tree 1: interval start must be numerically less than its end")
+}
@@ -0,0 +1,590 @@
+package gointervaltree
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Segment is a maximal [Start, End) range covered by the same non-empty set of intervals.
+type Segment[T Coordinate] struct {
+	Start T
+	End   T
+}
+
+// coveredSegment is a Segment together with the intervals covering it.
+type coveredSegment[T Coordinate, D any] struct {
+	segment  Segment[T]
+	covering []Interval[T, D]
+}
+
+// depthSegment is a maximal range of constant non-zero overlap depth.
+type depthSegment[T Coordinate] struct {
+	start T
+	end   T
+	depth int
+}
+
+// depthSegments method sweeps interval endpoints and returns maximal ranges of constant non-zero overlap depth
+// in ascending order.
+func (tree *intervalTree[T, D]) depthSegments() []depthSegment[T] {
+	type event struct {
+		at    T
+		delta int
+	}
+	intervals := tree.Iter()
+	events := make([]event, 0, 2*len(intervals))
+	for _, i := range intervals {
+		events = append(events, event{i.start, 1}, event{i.end, -1})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].at < events[j].at
+	})
+	var result []depthSegment[T]
+	depth := 0
+	for k := 0; k < len(events); {
+		at := events[k].at
+		for ; k < len(events) && events[k].at == at; k++ {
+			depth += events[k].delta
+		}
+		if depth == 0 || k == len(events) {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].end == at && result[n-1].depth == depth {
+			result[n-1].end = events[k].at
+		} else {
+			result = append(result, depthSegment[T]{start: at, end: events[k].at, depth: depth})
+		}
+	}
+	return result
+}
+
+// coveredSegments method sweeps interval endpoints and returns covered segments in ascending order,
+// each with the intervals covering it sorted by start and then by end. Zero-length intervals of closed trees
+// cover no segment and are skipped.
+func (tree *intervalTree[T, D]) coveredSegments() []coveredSegment[T, D] {
+	intervals := slices.DeleteFunc(tree.sortedIntervals(), func(i Interval[T, D]) bool {
+		return i.start == i.end
+	})
+	boundaries := make([]T, 0, 2*len(intervals))
+	for _, i := range intervals {
+		boundaries = append(boundaries, i.start, i.end)
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i] < boundaries[j]
+	})
+	var result []coveredSegment[T, D]
+	var active []Interval[T, D]
+	next := 0
+	for k := 0; k < len(boundaries)-1; k++ {
+		at := boundaries[k]
+		if at == boundaries[k+1] {
+			continue
+		}
+		kept := active[:0]
+		for _, i := range active {
+			if i.end > at {
+				kept = append(kept, i)
+			}
+		}
+		active = kept
+		for ; next < len(intervals) && intervals[next].start == at; next++ {
+			active = append(active, intervals[next])
+		}
+		if len(active) > 0 {
+			covering := make([]Interval[T, D], len(active))
+			copy(covering, active)
+			result = append(result, coveredSegment[T, D]{segment: Segment[T]{Start: at, End: boundaries[k+1]}, covering: covering})
+		}
+	}
+	return result
+}
+
+// ReduceSegments folds fn over the covered segments of the tree in ascending order, passing every segment together
+// with the intervals covering it, and returns the final accumulator. It is a function rather than a method since
+// Go methods cannot declare their own type parameters.
+func ReduceSegments[T Coordinate, D any, R any](tree *IntervalTree[T, D], init R, fn func(acc R, segment Segment[T], covering []Interval[T, D]) R) R {
+	acc := init
+	for _, s := range tree.coveredSegments() {
+		acc = fn(acc, s.segment, s.covering)
+	}
+	return acc
+}
+
+// CoveredRuns method returns maximal contiguous ranges covered by at least one interval in ascending order,
+// each annotated with the minimum and maximum overlap depth observed within it.
+func (tree *intervalTree[T, D]) CoveredRuns() []struct {
+	Start, End         T
+	MinDepth, MaxDepth int
+} {
+	var result []struct {
+		Start, End         T
+		MinDepth, MaxDepth int
+	}
+	for _, segment := range tree.depthSegments() {
+		if n := len(result); n > 0 && result[n-1].End == segment.start {
+			result[n-1].End = segment.end
+			if segment.depth < result[n-1].MinDepth {
+				result[n-1].MinDepth = segment.depth
+			}
+			if segment.depth > result[n-1].MaxDepth {
+				result[n-1].MaxDepth = segment.depth
+			}
+			continue
+		}
+		result = append(result, struct {
+			Start, End         T
+			MinDepth, MaxDepth int
+		}{segment.start, segment.end, segment.depth, segment.depth})
+	}
+	return result
+}
+
+// coveredSpans method returns maximal ranges covered by at least one interval in ascending order with zero data,
+// overlapping and adjacent intervals are merged together.
+func (tree *intervalTree[T, D]) coveredSpans() []Interval[T, D] {
+	var result []Interval[T, D]
+	for _, element := range tree.sortedIntervals() {
+		if n := len(result); n > 0 && element.start <= result[n-1].end {
+			if element.end > result[n-1].end {
+				result[n-1].end = element.end
+			}
+			continue
+		}
+		result = append(result, Interval[T, D]{start: element.start, end: element.end})
+	}
+	return result
+}
+
+// CoverageTree method returns a new sorted tree with the same bounds and options holding the merged, non-overlapping
+// spans covered by intervals of the tree with zero data. It answers "is x covered" queries over the smallest possible
+// set of intervals.
+func (tree *intervalTree[T, D]) CoverageTree() (*IntervalTree[T, D], error) {
+	coverage, err := tree.derive(tree.min, tree.max)
+	if err != nil {
+		return nil, err
+	}
+	for _, span := range tree.coveredSpans() {
+		if err = coverage.AddInterval(span.start, span.end, span.data); err != nil {
+			return nil, err
+		}
+	}
+	coverage.Sort()
+	return coverage, nil
+}
+
+// QueryCoverage method returns the intervals overlapping [qStart, qEnd) sorted by start and then by end, together
+// with the maximal sub-ranges of [qStart, qEnd) not covered by any interval (gaps, with zero data) in ascending order.
+// An empty query range yields no results.
+func (tree *intervalTree[T, D]) QueryCoverage(qStart, qEnd T) (covering []Interval[T, D], gaps []Interval[T, D]) {
+	if !(qStart < qEnd) {
+		return nil, nil
+	}
+	tree.visitRange(qStart, qEnd, func(i *interval[T, D]) bool {
+		covering = append(covering, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		return true
+	})
+	sort.SliceStable(covering, func(i, j int) bool {
+		if covering[i].start != covering[j].start {
+			return covering[i].start < covering[j].start
+		}
+		return covering[i].end < covering[j].end
+	})
+	cursor := qStart
+	for _, i := range covering {
+		if i.start > cursor {
+			gaps = append(gaps, Interval[T, D]{start: cursor, end: i.start})
+		}
+		if i.end > cursor {
+			cursor = i.end
+		}
+		if cursor >= qEnd {
+			break
+		}
+	}
+	if cursor < qEnd {
+		gaps = append(gaps, Interval[T, D]{start: cursor, end: qEnd})
+	}
+	return covering, gaps
+}
+
+// BusyFraction method returns the fraction in [0, 1] of the tree bounds [min, max) covered by at least one interval,
+// i.e. the union coverage within the bounds divided by max - min. An empty tree yields 0.
+func (tree *intervalTree[T, D]) BusyFraction() float64 {
+	var covered float64
+	for _, span := range tree.coveredSpans() {
+		start, end := max(span.start, tree.min), min(span.end, tree.max)
+		if start < end {
+			covered += float64(end) - float64(start)
+		}
+	}
+	return covered / (float64(tree.max) - float64(tree.min))
+}
+
+// CoveredFractionOf method returns the fraction of [qs, qe) covered by the union of intervals maintained in the
+// tree, so overlapping intervals are not counted twice. An empty query range yields 0.
+func (tree *intervalTree[T, D]) CoveredFractionOf(qs, qe T) float64 {
+	if !(qs < qe) {
+		return 0
+	}
+	_, gaps := tree.QueryCoverage(qs, qe)
+	length := float64(qe) - float64(qs)
+	uncovered := 0.0
+	for _, gap := range gaps {
+		uncovered += float64(gap.end) - float64(gap.start)
+	}
+	return (length - uncovered) / length
+}
+
+// TotalCoverage method returns the total length covered by at least one interval, i.e. the length of the union of
+// all intervals, so overlapping [0, 10) and [5, 15) yield 15 rather than 20. Overlapping and adjacent intervals are
+// merged before their lengths are summed. Intervals are half-open, so adjacent [0, 5) and [5, 10) share no
+// coordinate and yield 10.
+func (tree *intervalTree[T, D]) TotalCoverage() T {
+	var length T
+	for _, span := range tree.coveredSpans() {
+		length += span.end - span.start
+	}
+	return length
+}
+
+// Density method returns the number of intervals per unit of covered length, i.e. Len divided by the length of the
+// union of all intervals. The covered length rather than the tree bounds is used as the denominator so that density
+// does not depend on how generously the bounds were chosen, BusyFraction reports the share of the bounds covered.
+// An empty tree yields 0.
+func (tree *intervalTree[T, D]) Density() float64 {
+	length := tree.TotalCoverage()
+	if length == 0 {
+		return 0
+	}
+	return float64(tree.Len()) / float64(length)
+}
+
+// BuildSegmentIndex method computes and caches the covered segments of the tree so that SegmentAt answers with
+// a binary search instead of a sweep. The cache is dropped by any mutation of the tree.
+func (tree *intervalTree[T, D]) BuildSegmentIndex() {
+	tree.segmentIndex = tree.coveredSegments()
+	if tree.segmentIndex == nil {
+		tree.segmentIndex = []coveredSegment[T, D]{}
+	}
+}
+
+// segments method returns the cached segment index or computes the covered segments if no index is built.
+func (tree *intervalTree[T, D]) segments() []coveredSegment[T, D] {
+	if tree.segmentIndex != nil {
+		return tree.segmentIndex
+	}
+	return tree.coveredSegments()
+}
+
+// findSegment returns the index of the segment containing x or -1 if no segment contains it.
+func findSegment[T Coordinate, D any](segments []coveredSegment[T, D], x T) int {
+	k := sort.Search(len(segments), func(i int) bool {
+		return segments[i].segment.End > x
+	})
+	if k == len(segments) || segments[k].segment.Start > x {
+		return -1
+	}
+	return k
+}
+
+// SegmentAt method returns the covered segment containing x together with the intervals covering it sorted by start
+// and then by end, ok is false if x is not covered. Without a segment index built by BuildSegmentIndex the segments
+// are recomputed on every call.
+func (tree *intervalTree[T, D]) SegmentAt(x T) (segment Segment[T], covering []Interval[T, D], ok bool) {
+	segments := tree.segments()
+	k := findSegment(segments, x)
+	if k < 0 {
+		return segment, nil, false
+	}
+	covering = make([]Interval[T, D], len(segments[k].covering))
+	copy(covering, segments[k].covering)
+	return segments[k].segment, covering, true
+}
+
+// LengthHistogram method returns the number of intervals per length bucket, where each interval length is floored
+// to a multiple of bucketSize. bucketSize must be positive.
+func (tree *intervalTree[T, D]) LengthHistogram(bucketSize T) (map[T]int, error) {
+	if bucketSize <= 0 {
+		return nil, errors.New("bucket size must be positive")
+	}
+	result := make(map[T]int)
+	for _, i := range tree.Iter() {
+		bucket := (i.end - i.start) / bucketSize
+		if isFloat[T]() {
+			bucket = T(math.Floor(float64(bucket)))
+		}
+		result[bucket*bucketSize]++
+	}
+	return result, nil
+}
+
+// SameSegment method reports whether a and b fall into the same covered segment, i.e. they are covered by the same
+// set of intervals with no gap or coverage change in between. Uncovered points never share a segment.
+// The segment index built by BuildSegmentIndex is used if present.
+func (tree *intervalTree[T, D]) SameSegment(a, b T) bool {
+	segments := tree.segments()
+	k := findSegment(segments, a)
+	return k >= 0 && k == findSegment(segments, b)
+}
+
+// InsertAndUpdate method adds an interval like AddInterval and, if a segment index was built by BuildSegmentIndex,
+// updates only the segments overlapping [start, end) instead of dropping the index. The updated index is identical
+// to the one a full rebuild would produce, zero-length intervals of closed trees leave it unchanged.
+func (tree *intervalTree[T, D]) InsertAndUpdate(start, end T, data D) error {
+	segments := tree.segmentIndex
+	if err := tree.AddInterval(start, end, data); err != nil {
+		return err
+	}
+	if segments == nil {
+		return nil
+	}
+	if start == end {
+		tree.segmentIndex = segments
+		return nil
+	}
+	added := Interval[T, D]{start: start, end: end, data: data}
+	with := func(covering []Interval[T, D]) []Interval[T, D] {
+		k := sort.Search(len(covering), func(i int) bool {
+			return covering[i].start > start || (covering[i].start == start && covering[i].end > end)
+		})
+		result := make([]Interval[T, D], 0, len(covering)+1)
+		result = append(result, covering[:k]...)
+		result = append(result, added)
+		return append(result, covering[k:]...)
+	}
+	updated := make([]coveredSegment[T, D], 0, len(segments)+2)
+	cursor := start
+	for _, s := range segments {
+		if s.segment.End <= start || s.segment.Start >= end {
+			if s.segment.Start >= end && cursor < end {
+				updated = append(updated, coveredSegment[T, D]{segment: Segment[T]{Start: cursor, End: end}, covering: with(nil)})
+				cursor = end
+			}
+			updated = append(updated, s)
+			continue
+		}
+		if s.segment.Start < start {
+			updated = append(updated, coveredSegment[T, D]{segment: Segment[T]{Start: s.segment.Start, End: start}, covering: s.covering})
+		} else if s.segment.Start > cursor {
+			updated = append(updated, coveredSegment[T, D]{segment: Segment[T]{Start: cursor, End: s.segment.Start}, covering: with(nil)})
+		}
+		cursor = min(s.segment.End, end)
+		updated = append(updated, coveredSegment[T, D]{segment: Segment[T]{Start: max(s.segment.Start, start), End: cursor}, covering: with(s.covering)})
+		if s.segment.End > end {
+			updated = append(updated, coveredSegment[T, D]{segment: Segment[T]{Start: end, End: s.segment.End}, covering: s.covering})
+		}
+	}
+	if cursor < end {
+		updated = append(updated, coveredSegment[T, D]{segment: Segment[T]{Start: cursor, End: end}, covering: with(nil)})
+	}
+	tree.segmentIndex = updated
+	return nil
+}
+
+// CoverageTrack method splits the tree bounds [min, max) into bins equal-width buckets and returns the average
+// overlap depth within each of them, accounting for buckets covered partially. It is computed from a single sweep
+// over interval endpoints. bins must be positive.
+func (tree *intervalTree[T, D]) CoverageTrack(bins int) ([]float64, error) {
+	if bins <= 0 {
+		return nil, errors.New("number of bins must be positive")
+	}
+	result := make([]float64, bins)
+	lower, upper := float64(tree.min), float64(tree.max)
+	width := (upper - lower) / float64(bins)
+	for _, segment := range tree.depthSegments() {
+		start, end := max(float64(segment.start), lower), min(float64(segment.end), upper)
+		for k := int((start - lower) / width); k < bins && start < end; k++ {
+			binEnd := lower + float64(k+1)*width
+			if k == bins-1 {
+				binEnd = upper
+			}
+			covered := min(end, binEnd) - start
+			if covered > 0 {
+				result[k] += covered * float64(segment.depth)
+				start += covered
+			}
+		}
+	}
+	for k := range result {
+		result[k] /= width
+	}
+	return result, nil
+}
+
+// FirstDepthAtLeast method returns the smallest coordinate within the tree bounds [min, max) covered by at least
+// k intervals, found with a sweep over interval endpoints. ok is false if the depth never reaches k within the bounds.
+func (tree *intervalTree[T, D]) FirstDepthAtLeast(k int) (x T, ok bool) {
+	if k <= 0 {
+		return tree.min, true
+	}
+	for _, segment := range tree.depthSegments() {
+		start, end := max(segment.start, tree.min), min(segment.end, tree.max)
+		if segment.depth >= k && start < end {
+			return start, true
+		}
+	}
+	return x, false
+}
+
+// TilingOf method returns the intervals overlapping [qStart, qEnd) sorted by start and then by end, and whether they
+// cover the whole range without gaps. Overlaps between the intervals are allowed. An empty range is never complete.
+func (tree *intervalTree[T, D]) TilingOf(qStart, qEnd T) (intervals []Interval[T, D], complete bool) {
+	if !(qStart < qEnd) {
+		return nil, false
+	}
+	intervals, gaps := tree.QueryCoverage(qStart, qEnd)
+	return intervals, len(gaps) == 0
+}
+
+// SlidingOverlapCount method slides a window [s, s+w) across the tree bounds starting at min with the given step
+// while s < max, and returns the number of intervals overlapping every window position. Counts are maintained with
+// a sweep over sorted interval starts and ends rather than a range query per window. w and step must be positive.
+func (tree *intervalTree[T, D]) SlidingOverlapCount(w, step T) ([]struct {
+	Start T
+	Count int
+}, error) {
+	if w <= 0 {
+		return nil, errors.New("window width must be positive")
+	}
+	if step <= 0 {
+		return nil, errors.New("window step must be positive")
+	}
+	intervals := tree.Iter()
+	starts := make([]T, 0, len(intervals))
+	ends := make([]T, 0, len(intervals))
+	for _, i := range intervals {
+		starts = append(starts, i.start)
+		ends = append(ends, i.end)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+	sort.Slice(ends, func(i, j int) bool {
+		return ends[i] < ends[j]
+	})
+	var result []struct {
+		Start T
+		Count int
+	}
+	started, ended := 0, 0
+	for s := tree.min; s < tree.max; s += step {
+		// an interval overlaps [s, s+w) if it starts before s+w and does not end at or before s, a window end
+		// overflowing T lies beyond every start
+		for started < len(starts) && (s+w < s || starts[started] < s+w) {
+			started++
+		}
+		for ended < len(ends) && ends[ended] <= s {
+			ended++
+		}
+		result = append(result, struct {
+			Start T
+			Count int
+		}{s, started - ended})
+		// stop once the next window would overflow T or, for a step below float precision, not advance
+		if s+step <= s {
+			break
+		}
+	}
+	return result, nil
+}
+
+// CoverageProfile method samples points from min to max inclusive in step increments and returns the number of
+// intervals covering every sampled point, as QueryCount reports it. step must be positive.
+func (tree *intervalTree[T, D]) CoverageProfile(step T) ([]struct {
+	Point T
+	Count int
+}, error) {
+	if step <= 0 {
+		return nil, errors.New("sampling step must be positive")
+	}
+	var result []struct {
+		Point T
+		Count int
+	}
+	for x := tree.min; x <= tree.max; x += step {
+		result = append(result, struct {
+			Point T
+			Count int
+		}{x, tree.QueryCount(x)})
+		// stop once the next sample would overflow T or, for a step below float precision, not advance
+		if x+step <= x {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Gaps method returns the maximal sub-ranges of the tree bounds [min, max) not covered by any interval
+// in ascending order with zero data. An empty tree yields a single gap spanning the bounds.
+func (tree *intervalTree[T, D]) Gaps() []Interval[T, D] {
+	_, gaps := tree.QueryCoverage(tree.min, tree.max)
+	return gaps
+}
+
+// GapTree method returns a new sorted tree with the same bounds and options holding the uncovered sub-ranges of
+// [min, max) with zero data, so that a point query on it tells whether a point is uncovered. It is the inverse of
+// CoverageTree. Gaps are computed with half-open semantics, so for a WithClosedIntervals tree a gap shares its
+// endpoints with the adjacent covered spans and points at gap boundaries are reported by both trees.
+func (tree *intervalTree[T, D]) GapTree() (*IntervalTree[T, D], error) {
+	gapTree, err := tree.derive(tree.min, tree.max)
+	if err != nil {
+		return nil, err
+	}
+	for _, gap := range tree.Gaps() {
+		if err = gapTree.AddInterval(gap.start, gap.end, gap.data); err != nil {
+			return nil, err
+		}
+	}
+	gapTree.Sort()
+	return gapTree, nil
+}
+
+// DepthDistribution method returns, for every overlap depth reached, the total length of coordinates covered by
+// exactly that many intervals, computed with a sweep over interval endpoints.
+func (tree *intervalTree[T, D]) DepthDistribution() map[int]T {
+	result := make(map[int]T)
+	for _, segment := range tree.depthSegments() {
+		result[segment.depth] += segment.end - segment.start
+	}
+	return result
+}
+
+// OverlapMetrics method compares the union coverage of the tree (predictions) with the union coverage of reference
+// base by base. Covered length shared by both is true positive, covered only by the tree is false positive and
+// covered only by reference is false negative. Precision, Recall and F1 are 0 where their denominators are zero.
+func (tree *intervalTree[T, D]) OverlapMetrics(reference *IntervalTree[T, D]) struct {
+	TruePositiveLen, FalsePositiveLen, FalseNegativeLen T
+	Precision, Recall, F1                               float64
+} {
+	var result struct {
+		TruePositiveLen, FalsePositiveLen, FalseNegativeLen T
+		Precision, Recall, F1                               float64
+	}
+	predicted, expected := tree.coveredSpans(), reference.coveredSpans()
+	for p, e := 0, 0; p < len(predicted) && e < len(expected); {
+		start, end := max(predicted[p].start, expected[e].start), min(predicted[p].end, expected[e].end)
+		if start < end {
+			result.TruePositiveLen += end - start
+		}
+		if predicted[p].end < expected[e].end {
+			p++
+		} else {
+			e++
+		}
+	}
+	result.FalsePositiveLen = tree.TotalCoverage() - result.TruePositiveLen
+	result.FalseNegativeLen = reference.TotalCoverage() - result.TruePositiveLen
+	tp, fp, fn := float64(result.TruePositiveLen), float64(result.FalsePositiveLen), float64(result.FalseNegativeLen)
+	if tp+fp > 0 {
+		result.Precision = tp / (tp + fp)
+	}
+	if tp+fn > 0 {
+		result.Recall = tp / (tp + fn)
+	}
+	if result.Precision+result.Recall > 0 {
+		result.F1 = 2 * result.Precision * result.Recall / (result.Precision + result.Recall)
+	}
+	return result
+}
@@ -0,0 +1,563 @@
+package gointervaltree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// Tests
+
+func TestIntervalTree_CoveredRuns(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, nil)
+	_ = tree.AddInterval(15, 30, nil)
+	_ = tree.AddInterval(30, 35, nil)
+	_ = tree.AddInterval(50, 60, nil)
+	_ = tree.AddInterval(50, 60, nil)
+	_ = tree.AddInterval(52, 55, nil)
+	tree.Sort()
+	runs := tree.CoveredRuns()
+	assert.Len(t, runs, 2)
+	assert.Equal(t, 10, runs[0].Start)
+	assert.Equal(t, 35, runs[0].End)
+	assert.Equal(t, 1, runs[0].MinDepth)
+	assert.Equal(t, 2, runs[0].MaxDepth)
+	assert.Equal(t, 50, runs[1].Start)
+	assert.Equal(t, 60, runs[1].End)
+	assert.Equal(t, 2, runs[1].MinDepth)
+	assert.Equal(t, 3, runs[1].MaxDepth)
+}
+
+func TestIntervalTree_CoveredRunsEmptyTree(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.CoveredRuns())
+}
+
+func TestReduceSegments(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, 2)
+	_ = tree.AddInterval(15, 30, 3)
+	_ = tree.AddInterval(50, 60, 1)
+	tree.Sort()
+	depthLength := ReduceSegments(tree, 0, func(acc int, segment Segment[int], covering []Interval[int, any]) int {
+		return acc + (segment.End-segment.Start)*len(covering)
+	})
+	assert.Equal(t, 10+15+10, depthLength)
+	weighted := ReduceSegments(tree, 0.0, func(acc float64, segment Segment[int], covering []Interval[int, any]) float64 {
+		for _, i := range covering {
+			acc += float64(segment.End-segment.Start) * float64(i.data.(int))
+		}
+		return acc
+	})
+	assert.Equal(t, 10*2.0+15*3.0+10*1.0, weighted)
+	var segments []Segment[int]
+	ReduceSegments(tree, 0, func(acc int, segment Segment[int], covering []Interval[int, any]) int {
+		segments = append(segments, segment)
+		return acc
+	})
+	assert.Equal(t, []Segment[int]{{10, 15}, {15, 20}, {20, 30}, {50, 60}}, segments)
+}
+
+func TestIntervalTree_CoverageTree(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		_ = tree.AddInterval(start, start+1+random.Intn(10), i)
+	}
+	tree.Sort()
+	coverage, err := tree.CoverageTree()
+	assert.NoError(t, err)
+	assert.Less(t, coverage.Len(), tree.Len())
+	assert.Equal(t, tree.min, coverage.min)
+	assert.Equal(t, tree.max, coverage.max)
+	for _, i := range coverage.Iter() {
+		assert.Nil(t, i.data)
+	}
+	for k := 0; k < 5000; k++ {
+		x := random.Intn(1100) - 50
+		assert.Equal(t, len(tree.Query(x)) > 0, len(coverage.Query(x)) > 0, "point %d", x)
+		assert.LessOrEqual(t, len(coverage.Query(x)), 1)
+	}
+}
+
+func TestIntervalTree_CoverageTreeMergesAdjacent(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(0, 10, nil)
+	_ = tree.AddInterval(10, 20, nil)
+	_ = tree.AddInterval(5, 15, nil)
+	_ = tree.AddInterval(30, 40, nil)
+	coverage, _ := tree.CoverageTree()
+	assert.ElementsMatch(t, []Interval[int, any]{{0, 20, nil}, {30, 40, nil}}, coverage.Iter())
+}
+
+func TestIntervalTree_QueryCoverage(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(30, 45, "b")
+	_ = tree.AddInterval(60, 70, "c")
+	tree.Sort()
+	covering, gaps := tree.QueryCoverage(15, 40)
+	assert.Equal(t, []Interval[int, any]{{10, 20, "a"}, {30, 45, "b"}}, covering)
+	assert.Equal(t, []Interval[int, any]{{20, 30, nil}}, gaps)
+	covering, gaps = tree.QueryCoverage(5, 50)
+	assert.Len(t, covering, 2)
+	assert.Equal(t, []Interval[int, any]{{5, 10, nil}, {20, 30, nil}, {45, 50, nil}}, gaps)
+	covering, gaps = tree.QueryCoverage(80, 90)
+	assert.Empty(t, covering)
+	assert.Equal(t, []Interval[int, any]{{80, 90, nil}}, gaps)
+	covering, gaps = tree.QueryCoverage(62, 68)
+	assert.Equal(t, []Interval[int, any]{{60, 70, "c"}}, covering)
+	assert.Empty(t, gaps)
+	covering, gaps = tree.QueryCoverage(20, 20)
+	assert.Empty(t, covering)
+	assert.Empty(t, gaps)
+}
+
+func TestIntervalTree_BusyFraction(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, 0.0, tree.BusyFraction())
+	_ = tree.AddInterval(0, 20, nil)
+	_ = tree.AddInterval(10, 30, nil)
+	_ = tree.AddInterval(60, 80, nil)
+	_ = tree.AddInterval(65, 70, nil)
+	tree.Sort()
+	assert.Equal(t, 0.5, tree.BusyFraction())
+	_ = tree.AddInterval(20, 120, nil)
+	tree.Sort()
+	assert.Equal(t, 1.0, tree.BusyFraction())
+}
+
+func TestIntervalTree_CoveredFractionOf(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, 0.0, tree.CoveredFractionOf(0, 10))
+	_ = tree.AddInterval(10, 20, nil)
+	_ = tree.AddInterval(12, 18, nil)
+	_ = tree.AddInterval(15, 20, nil)
+	tree.Sort()
+	assert.Equal(t, 0.5, tree.CoveredFractionOf(10, 30))
+	assert.Equal(t, 0.5, tree.CoveredFractionOf(0, 20))
+	assert.Equal(t, 1.0, tree.CoveredFractionOf(12, 16))
+	assert.Equal(t, 0.0, tree.CoveredFractionOf(20, 30))
+	assert.Equal(t, 0.0, tree.CoveredFractionOf(30, 20))
+}
+
+func TestIntervalTree_Density(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	assert.Equal(t, 0.0, tree.Density())
+	_ = tree.AddInterval(0, 10, nil)
+	_ = tree.AddInterval(5, 15, nil)
+	_ = tree.AddInterval(5, 15, nil)
+	_ = tree.AddInterval(100, 105, nil)
+	tree.Sort()
+	// 4 intervals over 15 + 5 covered units
+	assert.Equal(t, 0.2, tree.Density())
+}
+
+func TestIntervalTree_SegmentAt(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	_, _, ok := tree.SegmentAt(10)
+	assert.False(t, ok)
+	tree.BuildSegmentIndex()
+	_, _, ok = tree.SegmentAt(10)
+	assert.False(t, ok)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		start := random.Intn(950)
+		_ = tree.AddInterval(start, start+1+random.Intn(50), i)
+	}
+	tree.Sort()
+	assert.Nil(t, tree.segmentIndex)
+	segments := tree.coveredSegments()
+	tree.BuildSegmentIndex()
+	for x := -10; x < 1010; x++ {
+		segment, covering, ok := tree.SegmentAt(x)
+		found := false
+		for _, s := range segments {
+			if s.segment.Start <= x && x < s.segment.End {
+				found = true
+				assert.True(t, ok)
+				assert.Equal(t, s.segment, segment)
+				assert.Equal(t, s.covering, covering)
+				assert.ElementsMatch(t, tree.Query(x), covering)
+			}
+		}
+		if !found {
+			assert.False(t, ok, "point %d", x)
+		}
+	}
+	_ = tree.AddInterval(990, 995, nil)
+	assert.Nil(t, tree.segmentIndex)
+	segment, _, ok := tree.SegmentAt(992)
+	assert.True(t, ok)
+	assert.Equal(t, Segment[int]{990, 995}, segment)
+}
+
+func TestIntervalTree_LengthHistogram(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	histogram, err := tree.LengthHistogram(5)
+	assert.NoError(t, err)
+	assert.Empty(t, histogram)
+	_ = tree.AddInterval(0, 3, nil)
+	_ = tree.AddInterval(10, 17, nil)
+	_ = tree.AddInterval(20, 32, nil)
+	_ = tree.AddInterval(40, 45, nil)
+	_ = tree.AddInterval(50, 59, nil)
+	histogram, err = tree.LengthHistogram(5)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 5: 3, 10: 1}, histogram)
+	_, err = tree.LengthHistogram(0)
+	assert.EqualError(t, err, "bucket size must be positive")
+}
+
+func TestIntervalTree_SameSegment(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.False(t, tree.SameSegment(1, 1))
+	_ = tree.AddInterval(10, 30, nil)
+	_ = tree.AddInterval(20, 30, nil)
+	_ = tree.AddInterval(40, 50, nil)
+	tree.Sort()
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			tree.BuildSegmentIndex()
+		}
+		assert.True(t, tree.SameSegment(11, 19))
+		assert.True(t, tree.SameSegment(29, 20))
+		assert.False(t, tree.SameSegment(19, 20))
+		assert.False(t, tree.SameSegment(25, 45))
+		assert.False(t, tree.SameSegment(32, 35))
+		assert.True(t, tree.SameSegment(40, 49))
+	}
+}
+
+func TestIntervalTree_InsertAndUpdate(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	tree.BuildSegmentIndex()
+	random := rand.New(rand.NewSource(1))
+	seen := make(map[[2]int]bool)
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		end := start + 1 + random.Intn(40)
+		if seen[[2]int{start, end}] { // intervals with equal bounds are ordered arbitrarily by a rebuild
+			continue
+		}
+		seen[[2]int{start, end}] = true
+		assert.NoError(t, tree.InsertAndUpdate(start, end, i))
+		if i%10 == 0 {
+			assert.Equal(t, tree.coveredSegments(), tree.segmentIndex)
+		}
+	}
+	tree.Sort()
+	for x := 0; x < 1000; x++ {
+		_, covering, _ := tree.SegmentAt(x)
+		assert.ElementsMatch(t, tree.Query(x), covering)
+	}
+	assert.EqualError(t, tree.InsertAndUpdate(5, 5, nil), "interval start must be numerically less than its end")
+	assert.NotNil(t, tree.segmentIndex)
+
+	closed, _ := NewIntervalTreeWithOptions(0, 100, WithClosedIntervals())
+	_ = closed.AddInterval(5, 10, "a")
+	closed.BuildSegmentIndex()
+	assert.NoError(t, closed.InsertAndUpdate(5, 5, "b"))
+	assert.NoError(t, closed.InsertAndUpdate(20, 20, "c"))
+	assert.Equal(t, closed.coveredSegments(), closed.segmentIndex)
+	assert.Equal(t, []coveredSegment[int, any]{{Segment[int]{5, 10}, []Interval[int, any]{{5, 10, "a"}}}}, closed.segmentIndex)
+}
+
+func TestIntervalTree_InsertAndUpdateWithoutIndex(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.NoError(t, tree.InsertAndUpdate(10, 20, nil))
+	assert.Nil(t, tree.segmentIndex)
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestIntervalTree_CoverageTrack(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	track, err := tree.CoverageTrack(4)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 0, 0}, track)
+	_ = tree.AddInterval(0, 25, nil)
+	_ = tree.AddInterval(10, 35, nil)
+	_ = tree.AddInterval(60, 70, nil)
+	_ = tree.AddInterval(90, 120, nil)
+	tree.Sort()
+	track, err = tree.CoverageTrack(4)
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{(25 + 15) / 25.0, 10 / 25.0, 10 / 25.0, 10 / 25.0}, track, 1e-9)
+	_, err = tree.CoverageTrack(0)
+	assert.EqualError(t, err, "number of bins must be positive")
+}
+
+func TestIntervalTree_CoverageTrackTotal(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	random := rand.New(rand.NewSource(1))
+	total := 0
+	for i := 0; i < 200; i++ {
+		start := random.Intn(950)
+		length := 1 + random.Intn(50)
+		total += length
+		_ = tree.AddInterval(start, start+length, nil)
+	}
+	tree.Sort()
+	for _, bins := range []int{1, 3, 7, 64, 1000, 3000} {
+		track, err := tree.CoverageTrack(bins)
+		assert.NoError(t, err)
+		sum := 0.0
+		for _, depth := range track {
+			sum += depth * 1000 / float64(bins)
+		}
+		assert.InDelta(t, float64(total), sum, 1e-6, "bins %d", bins)
+	}
+}
+
+func TestIntervalTree_FirstDepthAtLeast(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	x, ok := tree.FirstDepthAtLeast(0)
+	assert.True(t, ok)
+	assert.Equal(t, 0, x)
+	_, ok = tree.FirstDepthAtLeast(1)
+	assert.False(t, ok)
+	_ = tree.AddInterval(-20, -5, nil)
+	_ = tree.AddInterval(-10, 10, nil)
+	_ = tree.AddInterval(20, 40, nil)
+	_ = tree.AddInterval(35, 50, nil)
+	_ = tree.AddInterval(38, 45, nil)
+	tree.Sort()
+	x, ok = tree.FirstDepthAtLeast(1)
+	assert.True(t, ok)
+	assert.Equal(t, 0, x)
+	x, ok = tree.FirstDepthAtLeast(2)
+	assert.True(t, ok)
+	assert.Equal(t, 35, x)
+	x, ok = tree.FirstDepthAtLeast(3)
+	assert.True(t, ok)
+	assert.Equal(t, 38, x)
+	_, ok = tree.FirstDepthAtLeast(4)
+	assert.False(t, ok)
+}
+
+func TestIntervalTree_TilingOf(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(20, 30, "b")
+	_ = tree.AddInterval(40, 50, "c")
+	_ = tree.AddInterval(45, 60, "d")
+	tree.Sort()
+	intervals, complete := tree.TilingOf(10, 30)
+	assert.True(t, complete)
+	assert.Equal(t, []Interval[int, any]{{10, 20, "a"}, {20, 30, "b"}}, intervals)
+	intervals, complete = tree.TilingOf(42, 58)
+	assert.True(t, complete)
+	assert.Len(t, intervals, 2)
+	intervals, complete = tree.TilingOf(15, 45)
+	assert.False(t, complete)
+	assert.Len(t, intervals, 3)
+	intervals, complete = tree.TilingOf(30, 30)
+	assert.False(t, complete)
+	assert.Empty(t, intervals)
+}
+
+func TestIntervalTree_SlidingOverlapCount(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	random := rand.New(rand.NewSource(1))
+	var intervals [][]int
+	for i := 0; i < 200; i++ {
+		start := random.Intn(1000)
+		intervals = append(intervals, []int{start, start + 1 + random.Intn(60)})
+		_ = tree.AddInterval(intervals[i][0], intervals[i][1], nil)
+	}
+	tree.Sort()
+	for _, ws := range [][]int{{1, 1}, {10, 10}, {25, 7}, {100, 50}, {3, 40}} {
+		counts, err := tree.SlidingOverlapCount(ws[0], ws[1])
+		assert.NoError(t, err)
+		assert.Len(t, counts, (1000+ws[1]-1)/ws[1])
+		for k, c := range counts {
+			assert.Equal(t, k*ws[1], c.Start)
+			expected := 0
+			for _, i := range intervals {
+				if i[0] < c.Start+ws[0] && c.Start < i[1] {
+					expected++
+				}
+			}
+			assert.Equal(t, expected, c.Count, "window %d of width %d", c.Start, ws[0])
+		}
+	}
+	_, err := tree.SlidingOverlapCount(0, 1)
+	assert.EqualError(t, err, "window width must be positive")
+	_, err = tree.SlidingOverlapCount(1, -1)
+	assert.EqualError(t, err, "window step must be positive")
+
+	wide, _ := NewIntervalTree(1e17, 2e17)
+	_ = wide.AddInterval(1e17, 1.5e17, nil)
+	windows, err := wide.SlidingOverlapCount(1e16, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []struct {
+		Start float64
+		Count int
+	}{{1e17, 1}}, windows)
+
+	small, _ := NewIntervalTree(int8(100), int8(127))
+	_ = small.AddInterval(110, 127, nil)
+	_ = small.AddInterval(125, 127, nil)
+	smallWindows, err := small.SlidingOverlapCount(20, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []struct {
+		Start int8
+		Count int
+	}{{100, 1}, {110, 2}, {120, 2}}, smallWindows)
+}
+
+func TestIntervalTree_GapTree(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	gapTree, err := tree.GapTree()
+	assert.NoError(t, err)
+	assert.Equal(t, []Interval[int, any]{{0, 100, nil}}, gapTree.Iter())
+	_ = tree.AddInterval(10, 20, nil)
+	_ = tree.AddInterval(15, 30, nil)
+	_ = tree.AddInterval(60, 100, nil)
+	tree.Sort()
+	gapTree, err = tree.GapTree()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Interval[int, any]{{0, 10, nil}, {30, 60, nil}}, gapTree.Iter())
+	assert.Empty(t, gapTree.Query(25))
+	assert.Equal(t, []Interval[int, any]{{30, 60, nil}}, gapTree.Query(45))
+	for x := 0; x < 100; x++ {
+		assert.NotEqual(t, len(tree.Query(x)) > 0, len(gapTree.Query(x)) > 0, "point %d", x)
+	}
+}
+
+func TestIntervalTree_DepthDistribution(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.DepthDistribution())
+	_ = tree.AddInterval(1, 5, nil)
+	_ = tree.AddInterval(3, 8, nil)
+	tree.Sort()
+	assert.Equal(t, map[int]int{1: 5, 2: 2}, tree.DepthDistribution())
+	_ = tree.AddInterval(4, 6, nil)
+	_ = tree.AddInterval(20, 30, nil)
+	_ = tree.AddInterval(30, 35, nil)
+	tree.Sort()
+	// depth 1: [1,3) [6,8) [20,35), depth 2: [3,4) [5,6), depth 3: [4,5)
+	assert.Equal(t, map[int]int{1: 19, 2: 2, 3: 1}, tree.DepthDistribution())
+}
+
+func TestIntervalTree_OverlapMetrics(t *testing.T) {
+	predicted, _ := NewIntervalTree(0, 100)
+	reference, _ := NewIntervalTree(0, 100)
+	metrics := predicted.OverlapMetrics(reference)
+	assert.Equal(t, 0, metrics.TruePositiveLen)
+	assert.Equal(t, 0.0, metrics.F1)
+
+	for _, i := range [][]int{{0, 20}, {10, 30}, {50, 60}} {
+		_ = predicted.AddInterval(i[0], i[1], nil)
+	}
+	for _, i := range [][]int{{20, 40}, {55, 70}, {80, 90}} {
+		_ = reference.AddInterval(i[0], i[1], nil)
+	}
+	predicted.Sort()
+	reference.Sort()
+	metrics = predicted.OverlapMetrics(reference)
+	assert.Equal(t, 15, metrics.TruePositiveLen)
+	assert.Equal(t, 25, metrics.FalsePositiveLen)
+	assert.Equal(t, 30, metrics.FalseNegativeLen)
+	assert.Equal(t, 15.0/40, metrics.Precision)
+	assert.Equal(t, 15.0/45, metrics.Recall)
+	assert.InDelta(t, 2*15.0/(40+45), metrics.F1, 1e-12)
+
+	metrics = predicted.OverlapMetrics(predicted)
+	assert.Equal(t, 40, metrics.TruePositiveLen)
+	assert.Equal(t, 1.0, metrics.Precision)
+	assert.Equal(t, 1.0, metrics.Recall)
+	assert.Equal(t, 1.0, metrics.F1)
+}
+
+func TestIntervalTree_TotalCoverage(t *testing.T) {
+	cases := []struct {
+		name      string
+		intervals [][]int
+		expected  int
+	}{
+		{"empty", nil, 0},
+		{"overlapping", [][]int{{0, 10}, {5, 15}}, 15},
+		{"disjoint", [][]int{{0, 10}, {20, 25}}, 15},
+		{"nested", [][]int{{0, 50}, {10, 20}, {15, 30}}, 50},
+		{"adjacent", [][]int{{0, 5}, {5, 10}}, 10},
+		{"mixed", [][]int{{60, 70}, {0, 5}, {5, 10}, {8, 12}, {30, 40}, {32, 35}}, 32},
+	}
+	for _, c := range cases {
+		tree, _ := NewIntervalTree(0, 100)
+		for _, i := range c.intervals {
+			_ = tree.AddInterval(i[0], i[1], nil)
+		}
+		tree.Sort()
+		assert.Equal(t, c.expected, tree.TotalCoverage(), c.name)
+	}
+}
+
+func TestIntervalTree_Gaps(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, []Interval[int, any]{{0, 100, nil}}, tree.Gaps())
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(30, 40, "b")
+	tree.Sort()
+	assert.Equal(t, []Interval[int, any]{{0, 10, nil}, {20, 30, nil}, {40, 100, nil}}, tree.Gaps())
+
+	_ = tree.AddInterval(0, 10, nil)
+	_ = tree.AddInterval(90, 100, nil)
+	assert.Equal(t, []Interval[int, any]{{20, 30, nil}, {40, 90, nil}}, tree.Gaps())
+	_ = tree.AddInterval(-10, 110, nil)
+	assert.Empty(t, tree.Gaps())
+
+	covered, _ := NewIntervalTree(0, 100)
+	_ = covered.AddInterval(0, 60, nil)
+	_ = covered.AddInterval(50, 100, nil)
+	covered.Sort()
+	assert.Empty(t, covered.Gaps())
+}
+
+func TestIntervalTree_CoverageProfile(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	_, err := tree.CoverageProfile(0)
+	assert.Error(t, err)
+	random := rand.New(rand.NewSource(6))
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		_ = tree.AddInterval(start, start+1+random.Intn(100), i)
+	}
+	tree.Sort()
+	profile, err := tree.CoverageProfile(7)
+	assert.NoError(t, err)
+	assert.Len(t, profile, 143)
+	assert.Equal(t, 0, profile[0].Point)
+	assert.Equal(t, 994, profile[len(profile)-1].Point)
+	for _, sample := range profile {
+		assert.Equal(t, tree.QueryCount(sample.Point), sample.Count, "point %d", sample.Point)
+	}
+	profile, _ = tree.CoverageProfile(250)
+	assert.Len(t, profile, 5)
+	assert.Equal(t, 1000, profile[4].Point)
+
+	small, _ := NewIntervalTree[int8](100, 127)
+	_ = small.AddInterval(110, 120, nil)
+	smallProfile, _ := small.CoverageProfile(10)
+	assert.Len(t, smallProfile, 3)
+	assert.Equal(t, 1, smallProfile[1].Count)
+}
+
+func TestIntervalTree_CoverageTreeClosedIntervals(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithClosedIntervals())
+	_ = tree.AddInterval(5, 5, nil)
+	_ = tree.AddInterval(10, 20, nil)
+	tree.Sort()
+	coverage, err := tree.CoverageTree()
+	assert.NoError(t, err)
+	for _, x := range []int{4, 5, 6, 10, 20, 21} {
+		assert.Equal(t, len(tree.Query(x)) > 0, len(coverage.Query(x)) > 0, "x=%d", x)
+	}
+	gaps, err := tree.GapTree()
+	assert.NoError(t, err)
+	assert.True(t, gaps.options.closed)
+	assert.Len(t, gaps.Query(50), 1)
+	assert.Empty(t, gaps.Query(15))
+}
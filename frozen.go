@@ -0,0 +1,32 @@
+package gointervaltree
+
+// FrozenView is an immutable snapshot of an intervalTree returned by IntervalTree.FrozenView. It exposes read methods
+// only and shares no state with the tree it was taken from, so any number of goroutines can query it concurrently
+// without synchronization. Being a distinct type, it cannot be passed where a mutable tree is expected.
+type FrozenView[T Coordinate, D any] struct {
+	tree *intervalTree[T, D]
+}
+
+// FrozenView method returns a sorted read-only snapshot of the tree. The snapshot is a deep copy of the tree
+// structure taken once, later mutations of the tree are not reflected in it. Interval data is shared, so data
+// reachable through pointers must not be mutated while the snapshot is queried.
+func (tree *intervalTree[T, D]) FrozenView() *FrozenView[T, D] {
+	snapshot := tree.clone(make(map[*interval[T, D]]*interval[T, D]))
+	snapshot.Sort()
+	return &FrozenView[T, D]{tree: snapshot}
+}
+
+// Query method returns all intervals in the snapshot which overlap given point, see intervalTree.Query.
+func (view *FrozenView[T, D]) Query(x T) []Interval[T, D] {
+	return view.tree.Query(x)
+}
+
+// Iter method returns a slice of all intervals maintained in the snapshot, see intervalTree.Iter.
+func (view *FrozenView[T, D]) Iter() []Interval[T, D] {
+	return view.tree.Iter()
+}
+
+// Len represents the number of intervals maintained in the snapshot.
+func (view *FrozenView[T, D]) Len() int {
+	return view.tree.Len()
+}
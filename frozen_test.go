@@ -0,0 +1,51 @@
+package gointervaltree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// Tests
+
+func TestIntervalTree_FrozenView(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	for _, i := range [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}} {
+		_ = tree.AddInterval(i[0], i[1], i[0])
+	}
+	var view *FrozenView[int, any] = tree.FrozenView()
+	tree.Sort()
+	assert.Equal(t, tree.Len(), view.Len())
+	assert.ElementsMatch(t, tree.Iter(), view.Iter())
+	for x := 0; x < 100; x++ {
+		assert.ElementsMatch(t, tree.Query(x), view.Query(x), "point %d", x)
+	}
+
+	_ = tree.AddInterval(0, 100, nil)
+	removed, _ := tree.RemoveInterval(45, 55, 45)
+	assert.True(t, removed)
+	assert.Equal(t, 10, view.Len())
+	assert.Len(t, view.Query(50), 4)
+	assert.Empty(t, view.Query(5))
+}
+
+func TestIntervalTree_FrozenViewConcurrentQueries(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	for i := 0; i < 1000; i++ {
+		_ = tree.AddInterval(i, i+10, i)
+	}
+	view := tree.FrozenView()
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for x := g; x < 1000; x += 16 {
+				assert.Len(t, view.Query(x), min(x, 9)+1)
+			}
+			assert.Equal(t, 1000, view.Len())
+			assert.Len(t, view.Iter(), 1000)
+		}(g)
+	}
+	wg.Wait()
+}
@@ -1,6 +1,6 @@
 module github.com/danilovkiri/gointervaltree
 
-go 1.19
+go 1.24
 
 require (
 	github.com/stretchr/testify v1.7.0
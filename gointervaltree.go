@@ -1,100 +1,590 @@
-// Package gointervaltree provides functionality for indexing a set of integer intervals, e.g. [start, end)
-// based on http://en.wikipedia.org/wiki/Interval_tree. Copyright 2022, Kirill Danilov. Licensed under MIT license.
+// Package gointervaltree provides functionality for indexing a set of integer or floating-point intervals,
+// e.g. [start, end) based on http://en.wikipedia.org/wiki/Interval_tree. Copyright 2022, Kirill Danilov.
+// Licensed under MIT license.
 package gointervaltree
 
 import (
 	"errors"
+	"fmt"
 	"golang.org/x/exp/constraints"
+	"reflect"
+	"slices"
 	"sort"
 )
 
-// resultInterval is a node of an intervalTree without technical fields
-type resultInterval[T constraints.Signed] struct {
+// Coordinate is a constraint permitting any integer or floating-point type as interval coordinates.
+type Coordinate interface {
+	constraints.Integer | constraints.Float
+}
+
+// Interval is a [start, end) interval with its data. It is returned by queries over an intervalTree
+// and used to pass intervals into tree methods.
+type Interval[T Coordinate, D any] struct {
 	start T
 	end   T
-	data  any
+	data  D
+}
+
+// NewInterval creates and returns an Interval object.
+func NewInterval[T Coordinate, D any](start, end T, data D) Interval[T, D] {
+	return Interval[T, D]{start: start, end: end, data: data}
+}
+
+// Start method returns the interval start.
+func (i Interval[T, D]) Start() T {
+	return i.start
+}
+
+// End method returns the interval end.
+func (i Interval[T, D]) End() T {
+	return i.end
+}
+
+// Data method returns the data attached to the interval.
+func (i Interval[T, D]) Data() D {
+	return i.data
 }
 
 // interval is a node of an intervalTree.
-type interval[T constraints.Signed] struct {
+type interval[T Coordinate, D any] struct {
 	start   T
 	end     T
-	data    any
+	data    D
 	blocked bool
 }
 
-// intervalTree struct defines data structure for indexing a set of integer intervals, e.g. [start, end).
-type intervalTree[T constraints.Signed] struct {
+// Tree is the basic set of operations of an interval tree, it is implemented by IntervalTree and SafeIntervalTree
+// so that code depending on a tree can accept either of them or a test double.
+type Tree[T Coordinate, D any] interface {
+	AddInterval(start, end T, data D) error
+	Sort()
+	Query(x T) []Interval[T, D]
+	Len() int
+	Iter() []Interval[T, D]
+}
+
+// IntervalTree is the exported name of the interval tree type returned by the constructors, it allows declaring
+// variables and struct fields holding a tree outside of this package.
+type IntervalTree[T Coordinate, D any] = intervalTree[T, D]
+
+var (
+	_ Tree[int, any] = (*IntervalTree[int, any])(nil)
+	_ Tree[int, any] = (*SafeIntervalTree[int, any])(nil)
+)
+
+// intervalTree struct defines data structure for indexing a set of integer or floating-point intervals, e.g. [start, end).
+type intervalTree[T Coordinate, D any] struct {
 	min              T
 	max              T
 	center           T
-	singleInterval   *interval[T]
-	leftSubtree      *intervalTree[T]
-	rightSubtree     *intervalTree[T]
-	midSortedByStart []*interval[T]
-	midSortedByEnd   []*interval[T]
+	singleInterval   *interval[T, D]
+	leftSubtree      *intervalTree[T, D]
+	rightSubtree     *intervalTree[T, D]
+	midSortedByStart []*interval[T, D]
+	midSortedByEnd   []*interval[T, D]
+	options          options
+	depth            int
+	peak             int
+	segmentIndex     []coveredSegment[T, D]
+	size             int
+	sorted           bool
+	tags             map[string]map[*interval[T, D]]struct{}
+}
+
+// Option configures an intervalTree created with NewIntervalTreeWithOptions or NewTypedIntervalTree.
+type Option func(*options)
+
+// options holds optional intervalTree settings.
+type options struct {
+	trackPeak bool
+	maxDepth  int
+	closed    bool
+}
+
+// WithPeakTracking option makes the tree record the peak number of simultaneously overlapping intervals
+// ever observed, see PeakConcurrency.
+func WithPeakTracking() Option {
+	return func(o *options) {
+		o.trackPeak = true
+	}
 }
 
-// NewIntervalTree creates and returns an IntervalTree object.
-func NewIntervalTree[T constraints.Signed](min, max T) (*intervalTree[T], error) {
-	tree := new(intervalTree[T])
+// WithMaxDepth option limits the tree to depth levels of nodes. A node at the last level never creates subtrees,
+// it keeps all intervals reaching it in a flat list which queries scan linearly. This bounds the recursion on
+// degenerate inputs, e.g. deeply nested intervals, at the cost of query time. A non-positive depth means no limit.
+func WithMaxDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = depth
+	}
+}
+
+// WithClosedIntervals option makes the tree treat intervals as closed [start, end] rather than half-open
+// [start, end), so that an interval also overlaps the point equal to its end and AddInterval accepts single-point
+// intervals with start equal to end. It applies to adding, removing and tagging intervals, to peak tracking and to
+// point queries, i.e. Query and the methods walking the same path such as QueryCount, Overlaps or QueryBatch.
+// Range, coverage and set methods keep half-open semantics. Without this option intervals are half-open.
+func WithClosedIntervals() Option {
+	return func(o *options) {
+		o.closed = true
+	}
+}
+
+// NewIntervalTree creates and returns an IntervalTree object holding data of any type.
+func NewIntervalTree[T Coordinate](min, max T) (*IntervalTree[T, any], error) {
+	return NewTypedIntervalTree[T, any](min, max)
+}
+
+// NewIntervalTreeWithOptions creates and returns an IntervalTree object holding data of any type configured with opts.
+func NewIntervalTreeWithOptions[T Coordinate](min, max T, opts ...Option) (*IntervalTree[T, any], error) {
+	return NewTypedIntervalTree[T, any](min, max, opts...)
+}
+
+// NewTypedIntervalTree creates and returns an IntervalTree object holding data of type D configured with opts,
+// so that query results carry typed data without type assertions.
+func NewTypedIntervalTree[T Coordinate, D any](min, max T, opts ...Option) (*IntervalTree[T, D], error) {
+	tree := new(intervalTree[T, D])
 	tree.min = min
 	tree.max = max
 	if !(tree.min < tree.max) {
 		return nil, errors.New("interval tree start must be numerically less than its end")
 	}
-	tree.center = (min + max) / 2
+	tree.center = midpoint(min, max)
 	tree.singleInterval = nil
 	tree.leftSubtree = nil
 	tree.rightSubtree = nil
-	tree.midSortedByStart = []*interval[T]{}
-	tree.midSortedByEnd = []*interval[T]{}
+	tree.midSortedByStart = []*interval[T, D]{}
+	tree.midSortedByEnd = []*interval[T, D]{}
+	for _, opt := range opts {
+		opt(&tree.options)
+	}
 	return tree, nil
 }
 
-// AddInterval method adds intervals to the tree without sorting them along the way.
-func (tree *intervalTree[T]) AddInterval(start, end T, data any) error {
-	if (end - start) <= 0 {
-		return errors.New("interval start must be numerically less than its end")
+// midpoint returns (a + b) / 2 rounded toward zero without overflowing T.
+func midpoint[T Coordinate](a, b T) T {
+	if (a < 0) != (b < 0) {
+		// a sum of operands with opposite signs cannot overflow
+		return (a + b) / 2
+	}
+	return a/2 + b/2 + (a-a/2*2+b-b/2*2)/2
+}
+
+// isFloat reports whether T is a floating-point type.
+func isFloat[T Coordinate]() bool {
+	var half T = 1
+	half /= 2
+	return half != 0
+}
+
+// AddInterval method adds intervals to the tree without sorting them along the way until the tree is sorted once,
+// see Sort.
+func (tree *intervalTree[T, D]) AddInterval(start, end T, data D) error {
+	if err := tree.checkBounds(start, end); err != nil {
+		return err
+	}
+	tree.insert(start, end, data)
+	return nil
+}
+
+// insert method adds an interval already validated by checkBounds to the tree, recording peak concurrency if
+// tracked. Trees derived with derive hold the options of their source, so intervals taken from the source need no
+// validation.
+func (tree *intervalTree[T, D]) insert(start, end T, data D) {
+	tree.addInterval(&interval[T, D]{start, end, data, false})
+	if tree.options.trackPeak {
+		tree.updatePeak(start, end)
 	}
+}
+
+// derive method creates an empty tree over [min, max) configured with the options of the tree, so that trees built
+// from the intervals of another tree keep its mode, e.g. WithClosedIntervals.
+func (tree *intervalTree[T, D]) derive(min, max T) (*IntervalTree[T, D], error) {
+	derived, err := NewTypedIntervalTree[T, D](min, max)
+	if err != nil {
+		return nil, err
+	}
+	derived.options = tree.options
+	return derived, nil
+}
+
+// AddIntervals method adds intervals to the tree in the given order like a loop of AddInterval calls. It stops at
+// the first invalid interval and returns an error reporting its index, intervals preceding it stay in the tree.
+func (tree *intervalTree[T, D]) AddIntervals(intervals ...Interval[T, D]) error {
+	for index, i := range intervals {
+		if err := tree.AddInterval(i.start, i.end, i.data); err != nil {
+			return fmt.Errorf("interval %d: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// addInterval method places an already validated interval into the tree keeping the interval pointer,
+// so that the identity of an interval does not change as it moves down the tree.
+func (tree *intervalTree[T, D]) addInterval(i *interval[T, D]) {
+	tree.invalidate()
+	tree.size++
 	if tree.singleInterval == nil {
-		tree.singleInterval = &interval[T]{start, end, data, false}
+		tree.singleInterval = i
 	} else if !tree.singleInterval.blocked { // singleInterval is not blocked
-		tree.addIntervalMain(tree.singleInterval.start, tree.singleInterval.end, tree.singleInterval.data)
-		tree.singleInterval.blocked = true
-		tree.addIntervalMain(start, end, data)
+		single := tree.singleInterval
+		tree.singleInterval = &interval[T, D]{single.start, single.end, single.data, true}
+		tree.addIntervalMain(single)
+		tree.addIntervalMain(i)
 	} else { // singleInterval is blocked
-		tree.addIntervalMain(start, end, data)
+		tree.addIntervalMain(i)
+	}
+}
+
+// flat method reports whether the node lies at the last level allowed by WithMaxDepth or its bounds are too narrow
+// to be split, i.e. the center coincides with a bound, so that a subtree would get the bounds of the node again,
+// e.g. a right subtree [999, 1000) holding intervals starting at or after the tree bounds [0, 1000). Such a node
+// has no subtrees and keeps all its intervals in the mid-lists whether they contain the center or not.
+func (tree *intervalTree[T, D]) flat() bool {
+	return (tree.options.maxDepth > 0 && tree.depth+1 >= tree.options.maxDepth) || tree.center == tree.min || tree.center == tree.max
+}
+
+// checkBounds method returns an error unless start and end form an interval the tree can hold.
+func (tree *intervalTree[T, D]) checkBounds(start, end T) error {
+	// bounds are compared rather than subtracted, end - start overflows for coordinates near the limits of T
+	if tree.options.closed {
+		if !(start <= end) {
+			return errors.New("interval start must not be numerically greater than its end")
+		}
+	} else if !(start < end) {
+		return errors.New("interval start must be numerically less than its end")
 	}
 	return nil
 }
 
-// addIntervalMain method is a technical method used inside AddInterval.
-func (tree *intervalTree[T]) addIntervalMain(start, end T, data any) {
-	if end <= tree.center {
+// endsBefore method reports whether an interval ending at end lies entirely before x, i.e. (end <= x) for half-open
+// intervals and (end < x) for closed ones, see WithClosedIntervals. It also routes intervals to the left subtree,
+// so that an interval straddling or touching the center always stays in the mid-lists.
+func (tree *intervalTree[T, D]) endsBefore(end, x T) bool {
+	if tree.options.closed {
+		return end < x
+	}
+	return end <= x
+}
+
+// invalidate method drops data cached for the current tree contents and must be called on every mutation.
+func (tree *intervalTree[T, D]) invalidate() {
+	tree.segmentIndex = nil
+}
+
+// intervals method returns pointers to all intervals maintained in the tree in Iter order.
+func (tree *intervalTree[T, D]) intervals() []*interval[T, D] {
+	var result []*interval[T, D]
+	if tree.singleInterval == nil {
+		return result
+	} else if !tree.singleInterval.blocked {
+		return append(result, tree.singleInterval)
+	}
+	if tree.leftSubtree != nil {
+		result = append(result, tree.leftSubtree.intervals()...)
+	}
+	if tree.rightSubtree != nil {
+		result = append(result, tree.rightSubtree.intervals()...)
+	}
+	return append(result, tree.midSortedByStart...)
+}
+
+// visitAll method calls fn for every interval maintained in the tree in Iter order and stops as soon as fn returns
+// false, in which case it returns false as well.
+func (tree *intervalTree[T, D]) visitAll(fn func(i *interval[T, D]) bool) bool {
+	if tree.singleInterval == nil {
+		return true
+	} else if !tree.singleInterval.blocked {
+		return fn(tree.singleInterval)
+	}
+	if tree.leftSubtree != nil && !tree.leftSubtree.visitAll(fn) {
+		return false
+	}
+	if tree.rightSubtree != nil && !tree.rightSubtree.visitAll(fn) {
+		return false
+	}
+	for _, i := range tree.midSortedByStart {
+		if !fn(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuild method replaces the contents of the tree with the given intervals and sorts it.
+func (tree *intervalTree[T, D]) rebuild(intervals []*interval[T, D]) {
+	tree.invalidate()
+	tree.size = 0
+	tree.singleInterval = nil
+	tree.leftSubtree = nil
+	tree.rightSubtree = nil
+	tree.midSortedByStart = []*interval[T, D]{}
+	tree.midSortedByEnd = []*interval[T, D]{}
+	for _, i := range intervals {
+		tree.addInterval(i)
+	}
+	tree.Sort()
+}
+
+// clone method returns a deep copy of the tree structure sharing no nodes or intervals with the original, data is
+// copied shallowly. remap collects the original to copy mapping of intervals so that a single interval referenced
+// from both mid-lists stays a single interval in the copy. Caches and tags are not copied.
+func (tree *intervalTree[T, D]) clone(remap map[*interval[T, D]]*interval[T, D]) *intervalTree[T, D] {
+	copyOf := func(i *interval[T, D]) *interval[T, D] {
+		if c, ok := remap[i]; ok {
+			return c
+		}
+		c := &interval[T, D]{i.start, i.end, i.data, i.blocked}
+		remap[i] = c
+		return c
+	}
+	result := &intervalTree[T, D]{
+		min:              tree.min,
+		max:              tree.max,
+		center:           tree.center,
+		options:          tree.options,
+		depth:            tree.depth,
+		peak:             tree.peak,
+		size:             tree.size,
+		sorted:           tree.sorted,
+		midSortedByStart: make([]*interval[T, D], 0, len(tree.midSortedByStart)),
+		midSortedByEnd:   make([]*interval[T, D], 0, len(tree.midSortedByEnd)),
+	}
+	if tree.singleInterval != nil {
+		result.singleInterval = copyOf(tree.singleInterval)
+	}
+	if tree.leftSubtree != nil {
+		result.leftSubtree = tree.leftSubtree.clone(remap)
+	}
+	if tree.rightSubtree != nil {
+		result.rightSubtree = tree.rightSubtree.clone(remap)
+	}
+	for _, i := range tree.midSortedByStart {
+		result.midSortedByStart = append(result.midSortedByStart, copyOf(i))
+	}
+	for _, i := range tree.midSortedByEnd {
+		result.midSortedByEnd = append(result.midSortedByEnd, copyOf(i))
+	}
+	return result
+}
+
+// updatePeak method raises the recorded peak concurrency to the maximum overlap depth found within [start, end).
+func (tree *intervalTree[T, D]) updatePeak(start, end T) {
+	type event struct {
+		at    T
+		delta int
+	}
+	var events []event
+	tree.visitSpan(start, end, tree.options.closed, func(i *interval[T, D]) bool {
+		events = append(events, event{max(i.start, start), 1}, event{min(i.end, end), -1})
+		return true
+	})
+	// a half-open interval ending at a coordinate is closed before one starting there is opened, while closed
+	// intervals, including single points, still hold their end, so openings go first
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at != events[j].at {
+			return events[i].at < events[j].at
+		}
+		if tree.options.closed {
+			return events[i].delta > events[j].delta
+		}
+		return events[i].delta < events[j].delta
+	})
+	depth := 0
+	for _, e := range events {
+		depth += e.delta
+		if depth > tree.peak {
+			tree.peak = depth
+		}
+	}
+}
+
+// PeakConcurrency method returns the largest number of simultaneously overlapping intervals observed at any point
+// during the lifetime of a tree created with the WithPeakTracking option, and zero otherwise. The peak never
+// decreases when intervals are taken out of the tree. Tracking costs each AddInterval an extra range query over
+// the new interval plus O(k log k) work, where k is the number of stored intervals it overlaps.
+func (tree *intervalTree[T, D]) PeakConcurrency() int {
+	return tree.peak
+}
+
+// addIntervalMain method is a technical method used inside addInterval.
+func (tree *intervalTree[T, D]) addIntervalMain(i *interval[T, D]) {
+	if tree.flat() {
+		tree.addIntervalMid(i)
+	} else if tree.endsBefore(i.end, tree.center) {
 		if tree.leftSubtree == nil {
-			tree.leftSubtree, _ = NewIntervalTree(tree.min, tree.center)
+			tree.leftSubtree = tree.newSubtree(tree.min, tree.center)
 		}
-		_ = tree.leftSubtree.AddInterval(start, end, data)
-	} else if start > tree.center {
+		tree.leftSubtree.addInterval(i)
+	} else if i.start > tree.center {
 		if tree.rightSubtree == nil {
-			tree.rightSubtree, _ = NewIntervalTree(tree.center, tree.max)
+			tree.rightSubtree = tree.newSubtree(tree.center, tree.max)
+		}
+		tree.rightSubtree.addInterval(i)
+	} else {
+		tree.addIntervalMid(i)
+	}
+}
+
+// newSubtree method creates an empty subtree over [min, max) one level below the node inheriting its options
+// and sorted state.
+func (tree *intervalTree[T, D]) newSubtree(min, max T) *intervalTree[T, D] {
+	subtree, _ := NewTypedIntervalTree[T, D](min, max)
+	subtree.options = tree.options
+	subtree.depth = tree.depth + 1
+	subtree.sorted = tree.sorted
+	return subtree
+}
+
+// addIntervalMid method is a technical method used inside addIntervalMain, it appends the interval to the mid-lists.
+func (tree *intervalTree[T, D]) addIntervalMid(i *interval[T, D]) {
+	if !tree.sorted {
+		tree.midSortedByStart = append(tree.midSortedByStart, i)
+		tree.midSortedByEnd = append(tree.midSortedByEnd, i)
+		return
+	}
+	// once sorted, the mid-lists are kept sorted so that Query stays correct without another Sort
+	byStart := sort.Search(len(tree.midSortedByStart), func(k int) bool {
+		element := tree.midSortedByStart[k]
+		return element.start > i.start || (element.start == i.start && element.end > i.end)
+	})
+	tree.midSortedByStart = slices.Insert(tree.midSortedByStart, byStart, i)
+	byEnd := sort.Search(len(tree.midSortedByEnd), func(k int) bool {
+		return tree.midSortedByEnd[k].end < i.end
+	})
+	tree.midSortedByEnd = slices.Insert(tree.midSortedByEnd, byEnd, i)
+}
+
+// RemoveInterval method removes one interval with the given bounds and data from the tree and reports whether
+// such an interval was found. Data is compared with reflect.DeepEqual. Sorted order of the remaining intervals is
+// preserved, so the tree does not need to be sorted again.
+func (tree *intervalTree[T, D]) RemoveInterval(start, end T, data D) (bool, error) {
+	if err := tree.checkBounds(start, end); err != nil {
+		return false, err
+	}
+	removed := tree.removeInterval(start, end, data)
+	if removed == nil {
+		return false, nil
+	}
+	tree.untag(removed)
+	return true, nil
+}
+
+// removeInterval method is a technical method used inside RemoveInterval, it follows the addIntervalMain routing
+// and returns the removed interval or nil. Nodes left without intervals are reset to the empty state.
+func (tree *intervalTree[T, D]) removeInterval(start, end T, data D) *interval[T, D] {
+	if tree.singleInterval == nil {
+		return nil
+	} else if !tree.singleInterval.blocked {
+		single := tree.singleInterval
+		if single.start != start || single.end != end || !reflect.DeepEqual(single.data, data) {
+			return nil
+		}
+		tree.invalidate()
+		tree.singleInterval = nil
+		tree.size = 0
+		return single
+	}
+	var removed *interval[T, D]
+	if tree.flat() {
+		removed = tree.removeIntervalMid(start, end, data)
+	} else if tree.endsBefore(end, tree.center) {
+		if tree.leftSubtree != nil {
+			removed = tree.leftSubtree.removeInterval(start, end, data)
+			if tree.leftSubtree.singleInterval == nil {
+				tree.leftSubtree = nil
+			}
+		}
+	} else if start > tree.center {
+		if tree.rightSubtree != nil {
+			removed = tree.rightSubtree.removeInterval(start, end, data)
+			if tree.rightSubtree.singleInterval == nil {
+				tree.rightSubtree = nil
+			}
 		}
-		_ = tree.rightSubtree.AddInterval(start, end, data)
 	} else {
-		tree.midSortedByStart = append(tree.midSortedByStart, &interval[T]{start, end, data, false})
-		tree.midSortedByEnd = append(tree.midSortedByEnd, &interval[T]{start, end, data, false})
+		removed = tree.removeIntervalMid(start, end, data)
+	}
+	if removed == nil {
+		return nil
+	}
+	tree.invalidate()
+	tree.size--
+	if len(tree.midSortedByStart) == 0 && tree.leftSubtree == nil && tree.rightSubtree == nil {
+		tree.singleInterval = nil
+	}
+	return removed
+}
+
+// removeIntervalMid method is a technical method used inside removeInterval, it removes a matching interval from
+// the mid-lists and returns it or nil.
+func (tree *intervalTree[T, D]) removeIntervalMid(start, end T, data D) *interval[T, D] {
+	var removed *interval[T, D]
+	for index, i := range tree.midSortedByStart {
+		if i.start == start && i.end == end && reflect.DeepEqual(i.data, data) {
+			removed = i
+			tree.midSortedByStart = append(tree.midSortedByStart[:index], tree.midSortedByStart[index+1:]...)
+			break
+		}
 	}
+	for index, i := range tree.midSortedByEnd {
+		if i == removed {
+			tree.midSortedByEnd = append(tree.midSortedByEnd[:index], tree.midSortedByEnd[index+1:]...)
+			break
+		}
+	}
+	return removed
 }
 
-// Sort method is used to sort intervals within the tree and must be invoked after adding intervals.
-func (tree *intervalTree[T]) Sort() {
+// DeleteWhere method removes every interval for which pred returns true and returns the number of removed
+// intervals. The remaining intervals are rebuilt into a sorted tree, so queries stay correct without another Sort.
+func (tree *intervalTree[T, D]) DeleteWhere(pred func(start, end T, data D) bool) int {
+	removed := 0
+	var kept []*interval[T, D]
+	for _, i := range tree.intervals() {
+		if pred(i.start, i.end, i.data) {
+			tree.untag(i)
+			removed++
+		} else {
+			kept = append(kept, i)
+		}
+	}
+	if removed > 0 {
+		tree.rebuild(kept)
+	}
+	return removed
+}
+
+// UpdateData method replaces the data of every interval with the given bounds by data and returns the number of
+// updated intervals. Bounds are unchanged, so intervals keep their places in the tree as well as their tags.
+func (tree *intervalTree[T, D]) UpdateData(start, end T, data D) (int, error) {
+	if err := tree.checkBounds(start, end); err != nil {
+		return 0, err
+	}
+	updated := 0
+	tree.visitContaining(start, end, func(i *interval[T, D]) {
+		if i.start == start && i.end == end {
+			i.data = data
+			updated++
+		}
+	})
+	if updated > 0 {
+		tree.invalidate()
+	}
+	return updated, nil
+}
+
+// Sort method is used to sort intervals within the tree and must be invoked after adding intervals. Intervals
+// added to a sorted tree are inserted at their sorted positions, so the tree does not need to be sorted again.
+func (tree *intervalTree[T, D]) Sort() {
+	tree.sorted = true
 	if tree.singleInterval == nil || !tree.singleInterval.blocked {
 		return
 	}
 	sort.Slice(tree.midSortedByStart, func(i, j int) bool {
-		return tree.midSortedByStart[i].start < tree.midSortedByStart[j].start
-
+		if tree.midSortedByStart[i].start != tree.midSortedByStart[j].start {
+			return tree.midSortedByStart[i].start < tree.midSortedByStart[j].start
+		}
+		return tree.midSortedByStart[i].end < tree.midSortedByStart[j].end
 	})
 	sort.Slice(tree.midSortedByEnd, func(i, j int) bool {
 		return tree.midSortedByEnd[i].end > tree.midSortedByEnd[j].end
@@ -109,13 +599,23 @@ func (tree *intervalTree[T]) Sort() {
 
 // Query method returns all intervals in the tree which overlap given point,
 // i.e. all (start, end, data) records, for which (start <= x < end).
-func (tree *intervalTree[T]) Query(x T) []resultInterval[T] {
-	var result []resultInterval[T]
+func (tree *intervalTree[T, D]) Query(x T) []Interval[T, D] {
+	var result []Interval[T, D]
 	if tree.singleInterval == nil {
 		return result
 	} else if !tree.singleInterval.blocked {
-		if tree.singleInterval.start <= x && x < tree.singleInterval.end {
-			result = append(result, resultInterval[T]{start: (*tree.singleInterval).start, end: (*tree.singleInterval).end, data: (*tree.singleInterval).data})
+		if tree.singleInterval.start <= x && !tree.endsBefore(tree.singleInterval.end, x) {
+			result = append(result, Interval[T, D]{start: (*tree.singleInterval).start, end: (*tree.singleInterval).end, data: (*tree.singleInterval).data})
+		}
+		return result
+	} else if tree.flat() {
+		for _, element := range tree.midSortedByStart {
+			if element.start > x {
+				break
+			}
+			if !tree.endsBefore(element.end, x) {
+				result = append(result, Interval[T, D]{start: (*element).start, end: (*element).end, data: (*element).data})
+			}
 		}
 		return result
 	} else if x < tree.center {
@@ -124,7 +624,7 @@ func (tree *intervalTree[T]) Query(x T) []resultInterval[T] {
 		}
 		for _, element := range tree.midSortedByStart {
 			if element.start <= x {
-				result = append(result, resultInterval[T]{start: (*element).start, end: (*element).end, data: (*element).data})
+				result = append(result, Interval[T, D]{start: (*element).start, end: (*element).end, data: (*element).data})
 			} else {
 				break
 			}
@@ -132,8 +632,8 @@ func (tree *intervalTree[T]) Query(x T) []resultInterval[T] {
 		return result
 	} else {
 		for _, element := range tree.midSortedByEnd {
-			if element.end > x {
-				result = append(result, resultInterval[T]{start: (*element).start, end: (*element).end, data: (*element).data})
+			if !tree.endsBefore(element.end, x) {
+				result = append(result, Interval[T, D]{start: (*element).start, end: (*element).end, data: (*element).data})
 			} else {
 				break
 			}
@@ -146,30 +646,41 @@ func (tree *intervalTree[T]) Query(x T) []resultInterval[T] {
 }
 
 // Len represents the number of intervals maintained in the tree, zero- or negative-size intervals are not registered.
-func (tree *intervalTree[T]) Len() int {
-	if tree.singleInterval == nil {
-		return 0
-	} else if !tree.singleInterval.blocked {
-		return 1
-	} else {
-		size := len(tree.midSortedByStart)
-		if tree.leftSubtree != nil {
-			size += tree.leftSubtree.Len()
-		}
-		if tree.rightSubtree != nil {
-			size += tree.rightSubtree.Len()
-		}
-		return size
-	}
+// Every node keeps the number of intervals below it up to date as intervals are added and removed, so Len takes
+// constant time.
+func (tree *intervalTree[T, D]) Len() int {
+	return tree.size
+}
+
+// Min method returns the lower bound of the tree.
+func (tree *intervalTree[T, D]) Min() T {
+	return tree.min
+}
+
+// Max method returns the upper bound of the tree.
+func (tree *intervalTree[T, D]) Max() T {
+	return tree.max
+}
+
+// Center method returns the center of the tree, i.e. the midpoint of its bounds rounded toward zero, which splits
+// intervals between the left and right subtrees.
+func (tree *intervalTree[T, D]) Center() T {
+	return tree.center
+}
+
+// Span method returns the length of the tree bounds, i.e. max - min. It is computed in T, so it overflows for
+// bounds further apart than T can represent, e.g. [-100, 100) in int8.
+func (tree *intervalTree[T, D]) Span() T {
+	return tree.max - tree.min
 }
 
 // Iter method returns a slice of all intervals maintained in the tree.
-func (tree *intervalTree[T]) Iter() []resultInterval[T] {
-	var result []resultInterval[T]
+func (tree *intervalTree[T, D]) Iter() []Interval[T, D] {
+	var result []Interval[T, D]
 	if tree.singleInterval == nil {
 		return result
 	} else if !tree.singleInterval.blocked {
-		result = append(result, resultInterval[T]{start: (*tree.singleInterval).start, end: (*tree.singleInterval).end, data: (*tree.singleInterval).data})
+		result = append(result, Interval[T, D]{start: (*tree.singleInterval).start, end: (*tree.singleInterval).end, data: (*tree.singleInterval).data})
 		return result
 	} else {
 		if tree.leftSubtree != nil {
@@ -180,8 +691,124 @@ func (tree *intervalTree[T]) Iter() []resultInterval[T] {
 		}
 		// cannot use `result = append(result, tree.midSortedByStart...)` due to explicit dereferencing
 		for _, i := range tree.midSortedByStart {
-			result = append(result, resultInterval[T]{start: (*i).start, end: (*i).end, data: (*i).data})
+			result = append(result, Interval[T, D]{start: (*i).start, end: (*i).end, data: (*i).data})
 		}
 		return result
 	}
 }
+
+// IterSorted method returns a slice of all intervals maintained in the tree sorted by start and then by end.
+// Unlike ForEach, it does not rely on the tree being sorted.
+func (tree *intervalTree[T, D]) IterSorted() []Interval[T, D] {
+	return tree.sortedIntervals()
+}
+
+// Clear method removes all intervals from the tree keeping its bounds and options, so that the tree can be refilled
+// without allocating a new one. Backing arrays of the root mid-lists are retained, tags, caches and the recorded
+// peak concurrency are reset.
+func (tree *intervalTree[T, D]) Clear() {
+	tree.invalidate()
+	clear(tree.midSortedByStart)
+	clear(tree.midSortedByEnd)
+	tree.midSortedByStart = tree.midSortedByStart[:0]
+	tree.midSortedByEnd = tree.midSortedByEnd[:0]
+	tree.singleInterval = nil
+	tree.leftSubtree = nil
+	tree.rightSubtree = nil
+	tree.size = 0
+	tree.sorted = false
+	tree.peak = 0
+	tree.tags = nil
+}
+
+// Clone method returns a deep copy of the tree, i.e. mutating either tree does not affect the other. Subtrees,
+// mid-lists, intervals and tags are duplicated, while interval data is copied shallowly, so data referencing memory
+// such as pointers, slices or maps is shared between both trees.
+func (tree *intervalTree[T, D]) Clone() *IntervalTree[T, D] {
+	remap := make(map[*interval[T, D]]*interval[T, D])
+	result := tree.clone(remap)
+	for tag, intervals := range tree.tags {
+		if result.tags == nil {
+			result.tags = make(map[string]map[*interval[T, D]]struct{}, len(tree.tags))
+		}
+		result.tags[tag] = make(map[*interval[T, D]]struct{}, len(intervals))
+		for i := range intervals {
+			result.tags[tag][remap[i]] = struct{}{}
+		}
+	}
+	return result
+}
+
+// ClampToBounds method truncates intervals extending beyond the tree bounds [min, max) to fit them and removes
+// intervals lying entirely outside of the bounds, then rebuilds and sorts the tree. It returns the number of
+// intervals modified or removed.
+func (tree *intervalTree[T, D]) ClampToBounds() int {
+	changed := 0
+	var kept []*interval[T, D]
+	for _, i := range tree.intervals() {
+		start, end := max(i.start, tree.min), min(i.end, tree.max)
+		if start == i.start && end == i.end {
+			kept = append(kept, i)
+			continue
+		}
+		changed++
+		if tree.checkBounds(start, end) == nil {
+			i.start, i.end = start, end
+			kept = append(kept, i)
+		} else {
+			tree.untag(i)
+		}
+	}
+	if changed > 0 {
+		tree.rebuild(kept)
+	}
+	return changed
+}
+
+// TagInterval method attaches tag to every interval maintained in the tree with the given bounds without changing
+// its data and reports whether any interval was tagged. Tags are kept in an index keyed by interval identity,
+// they survive Sort but are not part of any serialized form of the tree.
+func (tree *intervalTree[T, D]) TagInterval(start, end T, tag string) bool {
+	if tree.checkBounds(start, end) != nil {
+		return false
+	}
+	tagged := false
+	tree.visitContaining(start, end, func(i *interval[T, D]) {
+		if i.start == start && i.end == end {
+			if tree.tags == nil {
+				tree.tags = make(map[string]map[*interval[T, D]]struct{})
+			}
+			if tree.tags[tag] == nil {
+				tree.tags[tag] = make(map[*interval[T, D]]struct{})
+			}
+			tree.tags[tag][i] = struct{}{}
+			tagged = true
+		}
+	})
+	return tagged
+}
+
+// QueryByTag method returns all intervals carrying tag sorted by start and then by end.
+func (tree *intervalTree[T, D]) QueryByTag(tag string) []Interval[T, D] {
+	var result []Interval[T, D]
+	for i := range tree.tags[tag] {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].start != result[j].start {
+			return result[i].start < result[j].start
+		}
+		return result[i].end < result[j].end
+	})
+	return result
+}
+
+// untag method removes an interval leaving the tree from the tag index.
+func (tree *intervalTree[T, D]) untag(i *interval[T, D]) {
+	for tag, intervals := range tree.tags {
+		delete(intervals, i)
+		if len(intervals) == 0 {
+			delete(tree.tags, tag)
+		}
+	}
+}
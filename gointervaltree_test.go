@@ -3,7 +3,9 @@ package gointervaltree
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"math/rand"
+	"slices"
 	"sort"
 	"testing"
 )
@@ -23,7 +25,7 @@ func TestIntervalTree_AddIntervalBad(t *testing.T) {
 
 func TestIntervalTree_QueryEmptyTree(t *testing.T) {
 	tree, _ := NewIntervalTree(10, 50)
-	assert.Equal(t, []resultInterval[int](nil), tree.Query(1))
+	assert.Equal(t, []Interval[int, any](nil), tree.Query(1))
 }
 
 func TestIntervalTree_LenEmptyTree(t *testing.T) {
@@ -33,7 +35,7 @@ func TestIntervalTree_LenEmptyTree(t *testing.T) {
 
 func TestIntervalTree_IterEmptyTree(t *testing.T) {
 	tree, _ := NewIntervalTree(10, 50)
-	assert.Equal(t, []resultInterval[int](nil), tree.Iter())
+	assert.Equal(t, []Interval[int, any](nil), tree.Iter())
 }
 
 func TestNewIntervalTree(t *testing.T) {
@@ -52,6 +54,681 @@ func TestNewIntervalTree(t *testing.T) {
 
 }
 
+func TestIntervalTree_PeakConcurrency(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking())
+	assert.Equal(t, 0, tree.PeakConcurrency())
+	_ = tree.AddInterval(10, 20, nil)
+	assert.Equal(t, 1, tree.PeakConcurrency())
+	_ = tree.AddInterval(20, 30, nil)
+	assert.Equal(t, 1, tree.PeakConcurrency())
+	_ = tree.AddInterval(15, 25, nil)
+	assert.Equal(t, 2, tree.PeakConcurrency())
+	_ = tree.AddInterval(18, 22, nil)
+	assert.Equal(t, 3, tree.PeakConcurrency())
+	_ = tree.AddInterval(50, 60, nil)
+	_ = tree.AddInterval(30, 40, nil)
+	assert.Equal(t, 3, tree.PeakConcurrency())
+	_ = tree.AddInterval(5, 10, nil)
+	assert.Equal(t, 3, tree.PeakConcurrency())
+
+	untracked, _ := NewIntervalTreeWithOptions(0, 100)
+	_ = untracked.AddInterval(10, 20, nil)
+	_ = untracked.AddInterval(10, 20, nil)
+	assert.Equal(t, 0, untracked.PeakConcurrency())
+	_, err := NewIntervalTreeWithOptions(100, 0, WithPeakTracking())
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestIntervalTree_PeakConcurrencyClosedIntervals(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking(), WithClosedIntervals())
+	_ = tree.AddInterval(0, 5, nil)
+	_ = tree.AddInterval(5, 10, nil)
+	assert.Len(t, tree.Query(5), 2)
+	assert.Equal(t, 2, tree.PeakConcurrency())
+	_ = tree.AddInterval(5, 5, nil)
+	assert.Equal(t, 3, tree.PeakConcurrency())
+	_ = tree.AddInterval(50, 50, nil)
+	assert.Equal(t, 3, tree.PeakConcurrency())
+
+	points, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking(), WithClosedIntervals())
+	_ = points.AddInterval(42, 42, nil)
+	assert.Equal(t, 1, points.PeakConcurrency())
+
+	halfOpen, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking())
+	_ = halfOpen.AddInterval(0, 5, nil)
+	_ = halfOpen.AddInterval(5, 10, nil)
+	assert.Equal(t, 1, halfOpen.PeakConcurrency())
+}
+
+func TestIntervalTree_ClampToBounds(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 20, "inside")
+	_ = tree.AddInterval(90, 120, "clipped")
+	_ = tree.AddInterval(-5, 5, "clipped")
+	_ = tree.AddInterval(150, 160, "removed")
+	tree.Sort()
+	assert.Equal(t, 3, tree.ClampToBounds())
+	assert.ElementsMatch(t, []Interval[int, any]{{10, 20, "inside"}, {90, 100, "clipped"}, {0, 5, "clipped"}}, tree.Iter())
+	assert.Equal(t, []Interval[int, any]{{90, 100, "clipped"}}, tree.Query(95))
+	assert.Empty(t, tree.Query(155))
+	assert.Equal(t, 0, tree.ClampToBounds())
+}
+
+func TestIntervalTree_Tags(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.False(t, tree.TagInterval(10, 20, "selected"))
+	_ = tree.AddInterval(10, 20, "a")
+	assert.True(t, tree.TagInterval(10, 20, "selected"))
+	for _, i := range [][]int{{20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}, {90, 120}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	assert.True(t, tree.TagInterval(45, 56, "selected"))
+	assert.True(t, tree.TagInterval(90, 120, "highlighted"))
+	assert.False(t, tree.TagInterval(45, 57, "selected"))
+	assert.False(t, tree.TagInterval(20, 10, "selected"))
+	tree.Sort()
+	assert.Equal(t, []Interval[int, any]{{10, 20, "a"}, {45, 56, nil}}, tree.QueryByTag("selected"))
+	assert.Equal(t, []Interval[int, any]{{90, 120, nil}}, tree.QueryByTag("highlighted"))
+	assert.Empty(t, tree.QueryByTag("missing"))
+	assert.Equal(t, []Interval[int, any]{{10, 20, "a"}}, tree.Query(15))
+	tree.ClampToBounds()
+	assert.Equal(t, []Interval[int, any]{{90, 100, nil}}, tree.QueryByTag("highlighted"))
+	assert.Len(t, tree.QueryByTag("selected"), 2)
+}
+
+func TestIntervalTree_TagsClosedIntervals(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithClosedIntervals())
+	_ = tree.AddInterval(5, 5, "point")
+	_ = tree.AddInterval(50, 50, "center")
+	_ = tree.AddInterval(10, 20, nil)
+	tree.Sort()
+	assert.True(t, tree.TagInterval(5, 5, "points"))
+	assert.True(t, tree.TagInterval(50, 50, "points"))
+	assert.False(t, tree.TagInterval(6, 5, "points"))
+	assert.Equal(t, []Interval[int, any]{{5, 5, "point"}, {50, 50, "center"}}, tree.QueryByTag("points"))
+	assert.Empty(t, tree.DegenerateIntervals())
+	for _, i := range tree.intervals() {
+		if i.start == 5 {
+			i.end = 4
+		}
+	}
+	assert.Equal(t, []Interval[int, any]{{5, 4, "point"}}, tree.DegenerateIntervals())
+}
+
+func TestIntervalTree_RemoveInterval(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking())
+	_ = tree.AddInterval(10, 20, "a")
+	removed, err := tree.RemoveInterval(10, 20, "b")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	removed, _ = tree.RemoveInterval(10, 20, "a")
+	assert.True(t, removed)
+	assert.Equal(t, 0, tree.Len())
+	assert.Empty(t, tree.Query(15))
+
+	for _, i := range [][]int{{10, 20}, {15, 60}, {40, 70}, {45, 55}, {60, 90}, {1, 5}} {
+		_ = tree.AddInterval(i[0], i[1], []int{i[0], i[1]})
+	}
+	_ = tree.AddInterval(45, 55, []int{45, 55})
+	tree.Sort()
+	assert.True(t, tree.TagInterval(45, 55, "mid"))
+	assert.Equal(t, 4, tree.PeakConcurrency())
+	removed, _ = tree.RemoveInterval(45, 55, []int{45, 55})
+	assert.True(t, removed)
+	assert.Len(t, tree.Query(50), 3)
+	assert.Len(t, tree.QueryByTag("mid"), 1)
+	removed, _ = tree.RemoveInterval(45, 55, []int{45, 55})
+	assert.True(t, removed)
+	removed, _ = tree.RemoveInterval(45, 55, []int{45, 55})
+	assert.False(t, removed)
+	assert.Empty(t, tree.QueryByTag("mid"))
+	assert.Equal(t, 4, tree.PeakConcurrency())
+	for _, i := range [][]int{{1, 5}, {60, 90}, {15, 60}} {
+		removed, _ = tree.RemoveInterval(i[0], i[1], []int{i[0], i[1]})
+		assert.True(t, removed)
+	}
+	assert.ElementsMatch(t, []Interval[int, any]{{10, 20, []int{10, 20}}, {40, 70, []int{40, 70}}}, tree.Iter())
+	assert.Equal(t, []Interval[int, any]{{40, 70, []int{40, 70}}}, tree.Query(65))
+	assert.Empty(t, tree.Query(3))
+
+	_, err = tree.RemoveInterval(20, 10, nil)
+	assert.EqualError(t, err, "interval start must be numerically less than its end")
+}
+
+func TestIntervalTree_RemoveIntervalChurn(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tree, _ := NewIntervalTree(0, 1000)
+	var intervals [][]int
+	for i := 0; i < 500; i++ {
+		start := random.Intn(990)
+		end := start + 1 + random.Intn(1000-start)
+		intervals = append(intervals, []int{start, end})
+		_ = tree.AddInterval(start, end, i)
+	}
+	tree.Sort()
+	for i := 0; i < 500; i += 2 {
+		removed, err := tree.RemoveInterval(intervals[i][0], intervals[i][1], i)
+		assert.NoError(t, err)
+		assert.True(t, removed)
+	}
+	assert.Equal(t, 250, tree.Len())
+	for x := 0; x < 1000; x += 7 {
+		var expected []Interval[int, any]
+		for i := 1; i < 500; i += 2 {
+			if intervals[i][0] <= x && x < intervals[i][1] {
+				expected = append(expected, Interval[int, any]{intervals[i][0], intervals[i][1], i})
+			}
+		}
+		assert.ElementsMatch(t, expected, tree.Query(x), "point %d", x)
+	}
+}
+
+func TestIntervalTree_TypedData(t *testing.T) {
+	type gene struct {
+		name string
+	}
+	tree, err := NewTypedIntervalTree[int, *gene](0, 100, WithPeakTracking())
+	assert.NoError(t, err)
+	brca, tp53 := &gene{"BRCA1"}, &gene{"TP53"}
+	_ = tree.AddInterval(10, 40, brca)
+	_ = tree.AddInterval(30, 60, tp53)
+	tree.Sort()
+	var names []string
+	for _, i := range tree.Query(35) {
+		names = append(names, i.Data().name)
+	}
+	assert.ElementsMatch(t, []string{"BRCA1", "TP53"}, names)
+	assert.Equal(t, []Interval[int, *gene]{{30, 60, tp53}}, tree.Query(50))
+	assert.Equal(t, 2, tree.PeakConcurrency())
+	removed, _ := tree.RemoveInterval(10, 40, brca)
+	assert.True(t, removed)
+	assert.Empty(t, tree.Query(20))
+
+	_, err = NewTypedIntervalTree[int, string](10, 10)
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestIntervalTree_AddIntervalAfterSort(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(40, 60, "a")
+	_ = tree.AddInterval(45, 70, "b")
+	tree.Sort()
+	_ = tree.AddInterval(10, 55, "c")
+	_ = tree.AddInterval(48, 90, "d")
+	assert.ElementsMatch(t, []Interval[int, any]{{40, 60, "a"}, {45, 70, "b"}, {10, 55, "c"}, {48, 90, "d"}}, tree.Query(50))
+	assert.ElementsMatch(t, []Interval[int, any]{{10, 55, "c"}}, tree.Query(20))
+	assert.ElementsMatch(t, []Interval[int, any]{{45, 70, "b"}, {48, 90, "d"}}, tree.Query(65))
+
+	random := rand.New(rand.NewSource(1))
+	tree, _ = NewIntervalTree(0, 1000)
+	var intervals [][]int
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 100; i++ {
+			start := random.Intn(990)
+			end := start + 1 + random.Intn(1000-start)
+			intervals = append(intervals, []int{start, end})
+			_ = tree.AddInterval(start, end, nil)
+		}
+		if round == 0 {
+			tree.Sort()
+		}
+		for x := 0; x < 1000; x += 3 {
+			expected := 0
+			for _, i := range intervals {
+				if i[0] <= x && x < i[1] {
+					expected++
+				}
+			}
+			assert.Len(t, tree.Query(x), expected, "round %d, point %d", round, x)
+		}
+	}
+}
+
+func TestIntervalTree_Clear(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking())
+	for _, i := range [][]int{{10, 20}, {20, 30}, {45, 55}, {45, 56}, {46, 57}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	tree.TagInterval(45, 55, "a")
+	capacity := cap(tree.midSortedByStart)
+	tree.Clear()
+	assert.Equal(t, 0, tree.Len())
+	assert.Empty(t, tree.Query(50))
+	assert.Empty(t, tree.QueryByTag("a"))
+	assert.Equal(t, 0, tree.PeakConcurrency())
+	assert.Equal(t, capacity, cap(tree.midSortedByStart))
+
+	_ = tree.AddInterval(40, 60, "a")
+	_ = tree.AddInterval(5, 15, "b")
+	_ = tree.AddInterval(45, 70, "c")
+	tree.Sort()
+	assert.Equal(t, 3, tree.Len())
+	assert.ElementsMatch(t, []Interval[int, any]{{40, 60, "a"}, {45, 70, "c"}}, tree.Query(50))
+	assert.Equal(t, []Interval[int, any]{{5, 15, "b"}}, tree.Query(10))
+	assert.Equal(t, 2, tree.PeakConcurrency())
+}
+
+func TestIntervalTree_Clone(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 100, WithPeakTracking())
+	shared := []int{1}
+	for _, i := range [][]int{{10, 20}, {20, 30}, {45, 55}, {45, 56}, {46, 57}} {
+		_ = tree.AddInterval(i[0], i[1], shared)
+	}
+	tree.Sort()
+	tree.TagInterval(45, 55, "a")
+	before := tree.Iter()
+
+	clone := tree.Clone()
+	assert.Equal(t, before, clone.Iter())
+	assert.Equal(t, tree.QueryByTag("a"), clone.QueryByTag("a"))
+	assert.Equal(t, 3, clone.PeakConcurrency())
+	_ = clone.AddInterval(40, 60, nil)
+	_ = clone.AddInterval(1, 5, nil)
+	clone.TagInterval(1, 5, "a")
+	removed, _ := clone.RemoveInterval(45, 55, shared)
+	assert.True(t, removed)
+	assert.Equal(t, 6, clone.Len())
+	assert.Len(t, clone.Query(50), 3)
+	assert.Equal(t, []Interval[int, any]{{1, 5, nil}}, clone.QueryByTag("a"))
+
+	assert.Equal(t, 5, tree.Len())
+	assert.Equal(t, before, tree.Iter())
+	assert.Len(t, tree.Query(50), 3)
+	assert.Equal(t, []Interval[int, any]{{45, 55, shared}}, tree.QueryByTag("a"))
+	shared[0] = 2
+	assert.Equal(t, 2, clone.Query(15)[0].Data().([]int)[0])
+}
+
+func TestIntervalTree_FloatCoordinates(t *testing.T) {
+	tree, err := NewIntervalTree(0.0, 1.0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, tree.center)
+	_ = tree.AddInterval(0.125, 0.375, "a")
+	_ = tree.AddInterval(0.25, 0.625, "b")
+	_ = tree.AddInterval(0.5625, 0.875, "c")
+	assert.EqualError(t, tree.AddInterval(0.2, 0.2, nil), "interval start must be numerically less than its end")
+	assert.EqualError(t, tree.AddInterval(math.NaN(), 0.2, nil), "interval start must be numerically less than its end")
+	tree.Sort()
+	assert.ElementsMatch(t, []Interval[float64, any]{{0.125, 0.375, "a"}, {0.25, 0.625, "b"}}, tree.Query(0.3))
+	assert.ElementsMatch(t, []Interval[float64, any]{{0.25, 0.625, "b"}, {0.5625, 0.875, "c"}}, tree.Query(0.6))
+	assert.Empty(t, tree.Query(0.875))
+	assert.Equal(t, []float64{0.25, 0.5625}, tree.StabbingPoints())
+	histogram, _ := tree.LengthHistogram(0.125)
+	assert.Equal(t, map[float64]int{0.25: 2, 0.375: 1}, histogram)
+	_, err = NewIntervalTree(math.NaN(), 1.0)
+	assert.Error(t, err)
+}
+
+func TestIntervalTree_UnsignedCoordinates(t *testing.T) {
+	tree, err := NewIntervalTree(uint32(0), uint32(math.MaxUint32))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(math.MaxUint32/2), tree.center)
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(15, 4000000000, "b")
+	_ = tree.AddInterval(3000000000, math.MaxUint32, "c")
+	assert.EqualError(t, tree.AddInterval(20, 10, nil), "interval start must be numerically less than its end")
+	tree.Sort()
+	assert.Equal(t, 3, tree.Len())
+	assert.ElementsMatch(t, []Interval[uint32, any]{{10, 20, "a"}, {15, 4000000000, "b"}}, tree.Query(17))
+	assert.ElementsMatch(t, []Interval[uint32, any]{{15, 4000000000, "b"}, {3000000000, math.MaxUint32, "c"}}, tree.Query(3500000000))
+	assert.Equal(t, []uint32{19, math.MaxUint32 - 1}, tree.StabbingPoints())
+	a, b, ok := tree.FirstSpacingViolation(0)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[uint32, any]{10, 20, "a"}, a)
+	assert.Equal(t, Interval[uint32, any]{15, 4000000000, "b"}, b)
+
+	small, _ := NewIntervalTree(uint8(200), uint8(255))
+	assert.Equal(t, uint8(227), small.center)
+	signed, _ := NewIntervalTree(int8(-128), int8(127))
+	assert.Equal(t, int8(0), signed.center)
+	negative, _ := NewIntervalTree(int8(-128), int8(-101))
+	assert.Equal(t, int8(-114), negative.center)
+}
+
+func TestIntervalTree_AddIntervals(t *testing.T) {
+	var intervals []Interval[int, any]
+	for _, i := range [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}} {
+		intervals = append(intervals, NewInterval[int, any](i[0], i[1], i[0]))
+	}
+	bulk, _ := NewIntervalTree(0, 100)
+	assert.NoError(t, bulk.AddIntervals(intervals...))
+	looped, _ := NewIntervalTree(0, 100)
+	for _, i := range intervals {
+		_ = looped.AddInterval(i.Start(), i.End(), i.Data())
+	}
+	assert.Equal(t, looped.Iter(), bulk.Iter())
+	bulk.Sort()
+	looped.Sort()
+	assert.Equal(t, looped.Query(50), bulk.Query(50))
+
+	tree, _ := NewIntervalTree(0, 100)
+	err := tree.AddIntervals(NewInterval[int, any](1, 5, nil), NewInterval[int, any](5, 5, nil), NewInterval[int, any](6, 9, nil))
+	assert.EqualError(t, err, "interval 1: interval start must be numerically less than its end")
+	assert.Equal(t, 1, tree.Len())
+	assert.NoError(t, tree.AddIntervals())
+}
+
+func TestIntervalTree_ExtremeCoordinates(t *testing.T) {
+	tree, err := NewIntervalTree(math.MinInt64, math.MaxInt64)
+	assert.NoError(t, err)
+	assert.EqualError(t, tree.AddInterval(math.MaxInt64, math.MinInt64, nil), "interval start must be numerically less than its end")
+	assert.EqualError(t, tree.AddInterval(math.MaxInt64-1, -10, nil), "interval start must be numerically less than its end")
+	assert.EqualError(t, tree.AddInterval(math.MaxInt64, math.MaxInt64, nil), "interval start must be numerically less than its end")
+	assert.NoError(t, tree.AddInterval(math.MinInt64, math.MinInt64+1, "min"))
+	assert.NoError(t, tree.AddInterval(math.MaxInt64-1, math.MaxInt64, "max"))
+	assert.NoError(t, tree.AddInterval(math.MinInt64, math.MaxInt64, "all"))
+	assert.NoError(t, tree.AddInterval(-1, 1, "zero"))
+	tree.Sort()
+	assert.Equal(t, 4, tree.Len())
+	assert.ElementsMatch(t, []Interval[int, any]{{math.MinInt64, math.MinInt64 + 1, "min"}, {math.MinInt64, math.MaxInt64, "all"}}, tree.Query(math.MinInt64))
+	assert.ElementsMatch(t, []Interval[int, any]{{math.MaxInt64 - 1, math.MaxInt64, "max"}, {math.MinInt64, math.MaxInt64, "all"}}, tree.Query(math.MaxInt64-1))
+	assert.Empty(t, tree.Query(math.MaxInt64))
+	assert.Len(t, tree.Query(0), 2)
+	assert.Empty(t, tree.DegenerateIntervals())
+
+	small, _ := NewIntervalTree(int8(math.MinInt8), int8(math.MaxInt8))
+	assert.EqualError(t, small.AddInterval(100, -100, nil), "interval start must be numerically less than its end")
+	for start := math.MinInt8; start < math.MaxInt8; start += 5 {
+		assert.NoError(t, small.AddInterval(int8(start), int8(min(start+20, math.MaxInt8)), nil))
+	}
+	small.Sort()
+	for x := math.MinInt8; x <= math.MaxInt8; x++ {
+		expected := 0
+		for start := math.MinInt8; start < math.MaxInt8; start += 5 {
+			if start <= x && x < min(start+20, math.MaxInt8) {
+				expected++
+			}
+		}
+		assert.Len(t, small.Query(int8(x)), expected, "point %d", x)
+	}
+}
+
+// countingTree is a Tree test double recording the number of queries.
+type countingTree struct {
+	Tree[int, string]
+	queries int
+}
+
+func (c *countingTree) Query(x int) []Interval[int, string] {
+	c.queries++
+	return c.Tree.Query(x)
+}
+
+func TestTree(t *testing.T) {
+	type index struct {
+		tree *IntervalTree[int, string]
+	}
+	tree, _ := NewTypedIntervalTree[int, string](0, 100)
+	holder := index{tree: tree}
+	safe, _ := NewSafeIntervalTree[int, string](0, 100)
+	wrapped, _ := NewSafeIntervalTree[int, string](0, 100)
+	for _, tree := range []Tree[int, string]{holder.tree, safe, &countingTree{Tree: wrapped}} {
+		_ = tree.AddInterval(10, 20, "a")
+		tree.Sort()
+		assert.Equal(t, []Interval[int, string]{{10, 20, "a"}}, tree.Query(15))
+		assert.Equal(t, tree.Len(), len(tree.Iter()))
+	}
+	counting := &countingTree{Tree: holder.tree}
+	counting.Query(1)
+	counting.Query(2)
+	assert.Equal(t, 2, counting.queries)
+}
+
+func TestIntervalTree_IterSorted(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	assert.Empty(t, tree.IterSorted())
+	random := rand.New(rand.NewSource(2))
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		_ = tree.AddInterval(start, start+1+random.Intn(1000-start-1), i)
+	}
+	sorted := tree.IterSorted()
+	assert.ElementsMatch(t, tree.Iter(), sorted)
+	assert.True(t, sort.SliceIsSorted(sorted, func(i, j int) bool {
+		if sorted[i].start != sorted[j].start {
+			return sorted[i].start < sorted[j].start
+		}
+		return sorted[i].end < sorted[j].end
+	}))
+}
+
+func TestIntervalTree_MaxDepth(t *testing.T) {
+	const limit = 1 << 62
+	build := func(opts ...Option) *IntervalTree[int64, int] {
+		tree, _ := NewTypedIntervalTree[int64, int](0, limit, opts...)
+		// nested intervals shrinking towards both bounds send every interval one level deeper than the previous one
+		for k := 0; k < 62; k++ {
+			_ = tree.AddInterval(0, 1<<k, k)
+			_ = tree.AddInterval(limit-1<<k, limit, -k)
+		}
+		tree.Sort()
+		return tree
+	}
+	deep, tree := build(), build(WithMaxDepth(4))
+	assert.Greater(t, deep.Height(), 60)
+	assert.LessOrEqual(t, tree.Height(), 4)
+	assert.Equal(t, deep.Len(), tree.Len())
+	_ = tree.AddInterval(3, 5, 100)
+	_ = deep.AddInterval(3, 5, 100)
+	points := []int64{0, 1, 3, 4, 5, 1 << 20, 1<<61 - 1, 1 << 61, limit - 1<<20, limit - 1}
+	for _, x := range points {
+		var expected []Interval[int64, int]
+		for _, i := range tree.Iter() {
+			if i.start <= x && x < i.end {
+				expected = append(expected, i)
+			}
+		}
+		assert.ElementsMatch(t, expected, tree.Query(x), "x=%d", x)
+		assert.ElementsMatch(t, deep.Query(x), tree.Query(x), "x=%d", x)
+		assert.Equal(t, len(expected), tree.QueryCount(x), "x=%d", x)
+		first, _ := tree.FindFirstOverlap(x)
+		expectedFirst, _ := deep.FindFirstOverlap(x)
+		assert.Equal(t, expectedFirst.start, first.start, "x=%d", x)
+	}
+	for k, matches := range tree.QueryBatch(points) {
+		assert.ElementsMatch(t, deep.Query(points[k]), matches)
+	}
+	assert.ElementsMatch(t, deep.QueryContainedIn(2, 1<<10), tree.QueryContainedIn(2, 1<<10))
+	assert.Equal(t, deep.IterSorted(), tree.IterSorted())
+	var visited []Interval[int64, int]
+	tree.ForEach(func(i Interval[int64, int]) bool {
+		visited = append(visited, i)
+		return true
+	})
+	assert.Equal(t, tree.IterSorted(), visited)
+	removed, _ := tree.RemoveInterval(0, 4, 2)
+	assert.True(t, removed)
+	assert.NotContains(t, tree.Query(3), NewInterval[int64, int](0, 4, 2))
+	assert.Equal(t, deep.Len()-1, tree.Len())
+}
+
+func TestIntervalTree_ClosedIntervals(t *testing.T) {
+	halfOpen, _ := NewIntervalTree(0, 1000)
+	closed, _ := NewIntervalTreeWithOptions(0, 1000, WithClosedIntervals())
+	assert.Error(t, halfOpen.AddInterval(10, 10, nil))
+	assert.NoError(t, closed.AddInterval(10, 10, "point"))
+	assert.Error(t, closed.AddInterval(11, 10, nil))
+	random := rand.New(rand.NewSource(5))
+	intervals := []Interval[int, any]{{400, 500, "ends at center"}, {500, 600, "starts at center"}, {250, 500, nil}}
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990) / 10 * 10
+		intervals = append(intervals, Interval[int, any]{start, start + 10*(1+random.Intn(10)), i})
+	}
+	for _, i := range intervals {
+		assert.NoError(t, halfOpen.AddInterval(i.start, i.end, i.data))
+		assert.NoError(t, closed.AddInterval(i.start, i.end, i.data))
+	}
+	halfOpen.Sort()
+	closed.Sort()
+	assert.NoError(t, closed.Validate())
+	assert.NotContains(t, halfOpen.Query(500), NewInterval[int, any](400, 500, "ends at center"))
+	assert.Contains(t, closed.Query(500), NewInterval[int, any](400, 500, "ends at center"))
+	var points []int
+	for x := -10; x <= 1100; x += 5 {
+		points = append(points, x)
+	}
+	batch := closed.QueryBatch(points)
+	for k, x := range points {
+		var expectedHalfOpen, expectedClosed []Interval[int, any]
+		for _, i := range intervals {
+			if i.start <= x && x < i.end {
+				expectedHalfOpen = append(expectedHalfOpen, i)
+			}
+			if i.start <= x && x <= i.end {
+				expectedClosed = append(expectedClosed, i)
+			}
+		}
+		if x == 10 {
+			expectedClosed = append(expectedClosed, Interval[int, any]{10, 10, "point"})
+		}
+		assert.ElementsMatch(t, expectedHalfOpen, halfOpen.Query(x), "x=%d", x)
+		assert.ElementsMatch(t, expectedClosed, closed.Query(x), "x=%d", x)
+		assert.ElementsMatch(t, expectedClosed, batch[k], "x=%d", x)
+		assert.ElementsMatch(t, expectedClosed, slices.Collect(closed.QuerySortedSeq(x)), "x=%d", x)
+		assert.Equal(t, len(expectedClosed), closed.QueryCount(x), "x=%d", x)
+		assert.Equal(t, len(expectedClosed) > 0, closed.Overlaps(x), "x=%d", x)
+		_, found := closed.FindFirstOverlap(x)
+		assert.Equal(t, len(expectedClosed) > 0, found, "x=%d", x)
+	}
+	removed, err := closed.RemoveInterval(10, 10, "point")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, _ = closed.RemoveInterval(400, 500, "ends at center")
+	assert.True(t, removed)
+	assert.NoError(t, closed.Validate())
+}
+
+func TestIntervalTree_DeleteWhere(t *testing.T) {
+	build := func() *IntervalTree[int, any] {
+		tree, _ := NewIntervalTree(0, 1000)
+		random := rand.New(rand.NewSource(7))
+		for i := 0; i < 300; i++ {
+			start := random.Intn(990)
+			_ = tree.AddInterval(start, start+1+random.Intn(100), i)
+		}
+		return tree
+	}
+	tree := build()
+	assert.Equal(t, 0, tree.DeleteWhere(func(start, end int, data any) bool { return false }))
+	assert.Equal(t, 300, tree.Len())
+
+	tree.Sort()
+	assert.True(t, tree.TagInterval(tree.Iter()[0].start, tree.Iter()[0].end, "first"))
+	even := func(start, end int, data any) bool { return data.(int)%2 == 0 }
+	assert.Equal(t, 150, tree.DeleteWhere(even))
+	assert.Equal(t, 150, tree.Len())
+	assert.NoError(t, tree.Validate())
+	reference := build()
+	reference.Sort()
+	for x := 0; x < 1100; x += 3 {
+		var expected []Interval[int, any]
+		for _, i := range reference.Query(x) {
+			if !even(i.start, i.end, i.data) {
+				expected = append(expected, i)
+			}
+		}
+		assert.ElementsMatch(t, expected, tree.Query(x), "x=%d", x)
+	}
+	for _, i := range tree.Iter() {
+		assert.Equal(t, 1, i.data.(int)%2)
+	}
+
+	assert.Equal(t, 150, tree.DeleteWhere(func(start, end int, data any) bool { return true }))
+	assert.Equal(t, 0, tree.Len())
+	assert.Empty(t, tree.Query(500))
+	assert.Nil(t, tree.leftSubtree)
+	assert.Nil(t, tree.rightSubtree)
+	assert.Empty(t, tree.tags)
+
+	single, _ := NewIntervalTree(0, 10)
+	_ = single.AddInterval(1, 2, nil)
+	assert.Equal(t, 1, single.DeleteWhere(func(start, end int, data any) bool { return start == 1 }))
+	assert.Equal(t, 0, single.Len())
+	assert.NoError(t, single.AddInterval(3, 4, nil))
+	assert.Len(t, single.Query(3), 1)
+}
+
+func TestIntervalTree_UpdateData(t *testing.T) {
+	tree, _ := NewTypedIntervalTree[int, string](0, 100)
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(10, 20, "b")
+	_ = tree.AddInterval(10, 30, "c")
+	_ = tree.AddInterval(60, 70, "d")
+	tree.Sort()
+	tree.BuildSegmentIndex()
+	updated, err := tree.UpdateData(10, 20, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated)
+	assert.ElementsMatch(t, []Interval[int, string]{{10, 20, "x"}, {10, 20, "x"}, {10, 30, "c"}}, tree.Query(15))
+	_, covering, _ := tree.SegmentAt(15)
+	assert.ElementsMatch(t, []Interval[int, string]{{10, 20, "x"}, {10, 20, "x"}, {10, 30, "c"}}, covering)
+	updated, err = tree.UpdateData(60, 70, "y")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, []Interval[int, string]{{60, 70, "y"}}, tree.Query(65))
+	updated, err = tree.UpdateData(11, 20, "z")
+	assert.NoError(t, err)
+	assert.Zero(t, updated)
+	_, err = tree.UpdateData(20, 10, "z")
+	assert.Error(t, err)
+	assert.NoError(t, tree.Validate())
+}
+
+func TestIntervalTree_LenCached(t *testing.T) {
+	tree, _ := NewIntervalTreeWithOptions(0, 1000, WithMaxDepth(6))
+	random := rand.New(rand.NewSource(8))
+	var added []Interval[int, any]
+	for step := 0; step < 3000; step++ {
+		if len(added) > 0 && random.Intn(3) == 0 {
+			k := random.Intn(len(added))
+			removed, _ := tree.RemoveInterval(added[k].start, added[k].end, added[k].data)
+			assert.True(t, removed)
+			added = append(added[:k], added[k+1:]...)
+		} else {
+			start := random.Intn(1100) - 50
+			i := Interval[int, any]{start, start + 1 + random.Intn(100), step}
+			assert.NoError(t, tree.AddInterval(i.start, i.end, i.data))
+			added = append(added, i)
+		}
+		if step == 1000 {
+			tree.Sort()
+		}
+		if step%100 == 0 {
+			assert.Equal(t, len(tree.Iter()), tree.Len())
+			assert.Equal(t, len(added), tree.Len())
+			assert.NoError(t, tree.Validate())
+		}
+	}
+	clone := tree.Clone()
+	assert.Equal(t, len(added), clone.Len())
+	tree.DeleteWhere(func(start, end int, data any) bool { return start < 0 })
+	tree.ClampToBounds()
+	assert.Equal(t, len(tree.Iter()), tree.Len())
+	assert.NoError(t, tree.Validate())
+	tree.Clear()
+	assert.Equal(t, 0, tree.Len())
+	assert.Equal(t, len(added), clone.Len())
+}
+
+func TestIntervalTree_Bounds(t *testing.T) {
+	tree, _ := NewIntervalTree(-10, 91)
+	assert.Equal(t, -10, tree.Min())
+	assert.Equal(t, 91, tree.Max())
+	assert.Equal(t, 40, tree.Center())
+	assert.Equal(t, 101, tree.Span())
+	_ = tree.AddInterval(0, 1, nil)
+	_ = tree.AddInterval(80, 90, nil)
+	_ = tree.AddInterval(30, 50, nil)
+	left, right := tree.leftSubtree, tree.rightSubtree
+	assert.Equal(t, []int{-10, 40, 15, 50}, []int{left.Min(), left.Max(), left.Center(), left.Span()})
+	assert.Equal(t, []int{40, 91, 65, 51}, []int{right.Min(), right.Max(), right.Center(), right.Span()})
+
+	floats, _ := NewTypedIntervalTree[float64, string](0.5, 2)
+	assert.Equal(t, 1.25, floats.Center())
+	assert.Equal(t, 1.5, floats.Span())
+}
+
 func doTest(t *testing.T, min, max int, intervals [][]int, queryPoints []int) {
 	tree, _ := NewIntervalTree(min, max)
 	for _, interval := range intervals {
@@ -66,10 +743,10 @@ func doTest(t *testing.T, min, max int, intervals [][]int, queryPoints []int) {
 			}
 			return r[i].end > r[j].end
 		})
-		var trueR []resultInterval[int]
+		var trueR []Interval[int, any]
 		for _, interval := range intervals {
 			if (interval[0] <= q) && (q < interval[1]) {
-				trueR = append(trueR, resultInterval[int]{interval[0], interval[1], nil})
+				trueR = append(trueR, Interval[int, any]{interval[0], interval[1], nil})
 			}
 		}
 		sort.Slice(trueR, func(i, j int) bool {
@@ -128,9 +805,13 @@ func ExampleNewIntervalTree() {
 	fmt.Println(t.Len())
 	fmt.Println(t.Iter())
 	fmt.Println(t.Query(33))
+	for _, i := range t.Query(2) {
+		fmt.Println(i.Start(), i.End(), i.Data())
+	}
 
 	// Output:
 	// 4
 	// [{1 10 [a b]} {32 35 [1 2 3]} {32 38 <nil>} {20 30 [true false]}]
 	// [{32 35 [1 2 3]} {32 38 <nil>}]
+	// 1 10 [a b]
 }
@@ -0,0 +1,378 @@
+package gointervaltree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LoadText reads intervals from r and returns a sorted tree over [min, max). Each line holds `start end [data...]`
+// separated by any whitespace, the remainder of the line after end is stored as string data.
+// Blank lines and lines starting with '#' are skipped, parse errors report the line number.
+func LoadText[T Coordinate](r io.Reader, min, max T) (*IntervalTree[T, any], error) {
+	tree, err := NewIntervalTree(min, max)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		startField, rest := cutField(line)
+		endField, rest := cutField(rest)
+		if endField == "" {
+			return nil, fmt.Errorf("line %d: expected at least start and end fields", lineNumber)
+		}
+		start, err := parseCoordinate[T](startField)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		end, err := parseCoordinate[T](endField)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if err = tree.AddInterval(start, end, rest); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	tree.Sort()
+	return tree, nil
+}
+
+// cutField splits s into its first whitespace-delimited field and the trimmed remainder.
+func cutField(s string) (field, rest string) {
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// parseCoordinate parses a base-10 coordinate and checks that it fits into T. Integer types accept integers only,
+// floating-point types accept any syntax supported by strconv.ParseFloat.
+func parseCoordinate[T Coordinate](s string) (T, error) {
+	if isFloat[T]() {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+		if !math.IsInf(v, 0) && math.IsInf(float64(T(v)), 0) {
+			return 0, fmt.Errorf("coordinate %s is out of range", s)
+		}
+		return T(v), nil
+	}
+	var zero T
+	if zero-1 > 0 { // unsigned
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if uint64(T(v)) != v {
+			return 0, fmt.Errorf("coordinate %s is out of range", s)
+		}
+		return T(v), nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if int64(T(v)) != v {
+		return 0, fmt.Errorf("coordinate %s is out of range", s)
+	}
+	return T(v), nil
+}
+
+// ExportedInterval is a plain serializable representation of an interval maintained in the tree.
+type ExportedInterval[T Coordinate, D any] struct {
+	Start T
+	End   T
+	Data  D
+}
+
+// Export method returns all intervals maintained in the tree as ExportedInterval records sorted by start
+// and then by end, decoupled from the internal tree structure.
+func (tree *intervalTree[T, D]) Export() []ExportedInterval[T, D] {
+	intervals := tree.sortedIntervals()
+	result := make([]ExportedInterval[T, D], 0, len(intervals))
+	for _, i := range intervals {
+		result = append(result, ExportedInterval[T, D]{Start: i.start, End: i.end, Data: i.data})
+	}
+	return result
+}
+
+// Import creates a sorted tree over [min, max) holding the given records, it is the counterpart of Export.
+func Import[T Coordinate, D any](min, max T, records []ExportedInterval[T, D]) (*IntervalTree[T, D], error) {
+	tree, err := NewTypedIntervalTree[T, D](min, max)
+	if err != nil {
+		return nil, err
+	}
+	for index, record := range records {
+		if err = tree.AddInterval(record.Start, record.End, record.Data); err != nil {
+			return nil, fmt.Errorf("record %d: %w", index, err)
+		}
+	}
+	tree.Sort()
+	return tree, nil
+}
+
+// ToFrontendJSON method writes all intervals maintained in the tree to w as a compact JSON array of
+// {"s": start, "e": end, "d": data} objects sorted by start. Records are encoded one by one, so the whole payload
+// is never held in memory. Data must be serializable with encoding/json.
+func (tree *intervalTree[T, D]) ToFrontendJSON(w io.Writer) error {
+	type record struct {
+		S T   `json:"s"`
+		E T   `json:"e"`
+		D any `json:"d"`
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for index, i := range tree.sortedIntervals() {
+		if index > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		encoded, err := json.Marshal(record{S: i.start, E: i.end, D: i.data})
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(encoded); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// FromMap creates a sorted tree over [min, max) holding an interval [key[0], key[1]) for every map entry with the
+// entry value as data. Invalid ranges are skipped and reported together in the returned error, in which case the
+// tree holding the valid entries is returned as well.
+func FromMap[T Coordinate, D any](min, max T, m map[[2]T]D) (*IntervalTree[T, D], error) {
+	tree, err := NewTypedIntervalTree[T, D](min, max)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][2]T, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	var errs []error
+	for _, key := range keys {
+		if err = tree.AddInterval(key[0], key[1], m[key]); err != nil {
+			errs = append(errs, fmt.Errorf("range %v: %w", key, err))
+		}
+	}
+	tree.Sort()
+	return tree, errors.Join(errs...)
+}
+
+// BuildFromFunc creates a sorted tree over [min, max) from intervals pulled from next until it reports ok=false,
+// so that the input never has to be materialized. Invalid intervals are skipped and reported together in the
+// returned error, in which case the tree holding the valid intervals is returned as well.
+func BuildFromFunc[T Coordinate, D any](min, max T, next func() (start, end T, data D, ok bool)) (*IntervalTree[T, D], error) {
+	tree, err := NewTypedIntervalTree[T, D](min, max)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for index := 0; ; index++ {
+		start, end, data, ok := next()
+		if !ok {
+			break
+		}
+		if err = tree.AddInterval(start, end, data); err != nil {
+			errs = append(errs, fmt.Errorf("interval %d: %w", index, err))
+		}
+	}
+	tree.Sort()
+	return tree, errors.Join(errs...)
+}
+
+// encodedTree is the serialized representation of an intervalTree used by MarshalJSON, UnmarshalJSON, GobEncode
+// and GobDecode.
+type encodedTree[T Coordinate, D any] struct {
+	Min       T                       `json:"min"`
+	Max       T                       `json:"max"`
+	Closed    bool                    `json:"closed,omitempty"`
+	Intervals []encodedInterval[T, D] `json:"intervals"`
+}
+
+// encodedInterval is the serialized representation of an interval within an encodedTree.
+type encodedInterval[T Coordinate, D any] struct {
+	Start T `json:"start"`
+	End   T `json:"end"`
+	Data  D `json:"data"`
+}
+
+// encode method returns the tree bounds, whether intervals are closed and all intervals maintained in the tree
+// in Iter order.
+func (tree *intervalTree[T, D]) encode() encodedTree[T, D] {
+	encoded := encodedTree[T, D]{Min: tree.min, Max: tree.max, Closed: tree.options.closed}
+	tree.visitAll(func(i *interval[T, D]) bool {
+		encoded.Intervals = append(encoded.Intervals, encodedInterval[T, D]{i.start, i.end, i.data})
+		return true
+	})
+	return encoded
+}
+
+// decode method replaces the contents of the tree with the decoded tree by re-adding and sorting the decoded
+// intervals. Tree options are kept except for the closed-interval mode, which is taken from the decoded tree,
+// tags and caches are dropped.
+func (tree *intervalTree[T, D]) decode(decoded encodedTree[T, D]) error {
+	restored, err := NewTypedIntervalTree[T, D](decoded.Min, decoded.Max)
+	if err != nil {
+		return err
+	}
+	restored.options = tree.options
+	restored.options.closed = decoded.Closed
+	for index, i := range decoded.Intervals {
+		if err = restored.AddInterval(i.Start, i.End, i.Data); err != nil {
+			return fmt.Errorf("interval %d: %w", index, err)
+		}
+	}
+	restored.Sort()
+	*tree = *restored
+	return nil
+}
+
+// MarshalJSON method encodes the tree bounds and all intervals maintained in the tree in Iter order as
+// {"min": min, "max": max, "intervals": [{"start": start, "end": end, "data": data}, ...]}, trees created
+// WithClosedIntervals also get "closed": true. Data must be serializable with encoding/json.
+func (tree *intervalTree[T, D]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tree.encode())
+}
+
+// UnmarshalJSON method replaces the contents of the tree with a tree decoded from the MarshalJSON format by re-adding
+// and sorting the decoded intervals. Data is decoded into D, so a tree holding data of any type gets data
+// as produced by encoding/json for an interface value, e.g. float64 for numbers. Tree options are kept except for
+// the closed-interval mode, which is restored from the encoded tree, tags and caches are dropped.
+func (tree *intervalTree[T, D]) UnmarshalJSON(data []byte) error {
+	var decoded encodedTree[T, D]
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	return tree.decode(decoded)
+}
+
+// GobEncode method encodes the tree bounds, the closed-interval mode and all intervals maintained in the tree in Iter
+// order with encoding/gob.
+// Data must be encodable by gob, concrete types stored as data of an interface type such as any must be registered
+// with gob.Register before encoding and decoding.
+func (tree *intervalTree[T, D]) GobEncode() ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(tree.encode()); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// GobDecode method replaces the contents of the tree with a tree decoded from the GobEncode format by re-adding
+// and sorting the decoded intervals. Tree options are kept except for the closed-interval mode, which is restored
+// from the encoded tree, tags and caches are dropped.
+func (tree *intervalTree[T, D]) GobDecode(data []byte) error {
+	var decoded encodedTree[T, D]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+	return tree.decode(decoded)
+}
+
+// BuildFromIntervals creates a sorted tree holding the given intervals with bounds derived from the data, i.e. over
+// [smallest start, largest end), so that the bounds need not be known in advance.
+func BuildFromIntervals[T Coordinate, D any](intervals []Interval[T, D]) (*IntervalTree[T, D], error) {
+	if len(intervals) == 0 {
+		return nil, errors.New("at least one interval is required to build a tree")
+	}
+	lower, upper := intervals[0].start, intervals[0].end
+	for index, i := range intervals {
+		if !(i.start < i.end) {
+			return nil, fmt.Errorf("interval %d: interval start must be numerically less than its end", index)
+		}
+		lower, upper = min(lower, i.start), max(upper, i.end)
+	}
+	tree, err := NewTypedIntervalTree[T, D](lower, upper)
+	if err != nil {
+		return nil, err
+	}
+	if err = tree.AddIntervals(intervals...); err != nil {
+		return nil, err
+	}
+	tree.Sort()
+	return tree, nil
+}
+
+// LoadCSV reads intervals from CSV records `start,end[,fields...]` and returns a sorted tree with bounds derived from
+// the data as BuildFromIntervals does. parseData receives the fields following end, possibly none, and returns the
+// data of the interval. Malformed records and records rejected by parseData report the line number.
+func LoadCSV[T Coordinate, D any](r io.Reader, parseData func(fields []string) (D, error)) (*IntervalTree[T, D], error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var intervals []Interval[T, D]
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected at least start and end fields", line)
+		}
+		start, err := parseCoordinate[T](record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := parseCoordinate[T](record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !(start < end) {
+			return nil, fmt.Errorf("line %d: interval start must be numerically less than its end", line)
+		}
+		data, err := parseData(record[2:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		intervals = append(intervals, Interval[T, D]{start: start, end: end, data: data})
+	}
+	return BuildFromIntervals(intervals)
+}
+
+// WriteCSV method writes all intervals maintained in the tree to w as CSV records `start,end[,fields...]` sorted by
+// start and then by end, the trailing fields being produced by formatData. The output is readable by LoadCSV.
+func (tree *intervalTree[T, D]) WriteCSV(w io.Writer, formatData func(data D) []string) error {
+	writer := csv.NewWriter(w)
+	for _, i := range tree.sortedIntervals() {
+		record := append([]string{fmt.Sprint(i.start), fmt.Sprint(i.end)}, formatData(i.data)...)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,337 @@
+package gointervaltree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Tests
+
+func TestLoadText(t *testing.T) {
+	input := `# start end data
+10 20
+15	30  gene  BRCA1   plus
+
+40 50 exon
+`
+	tree, err := LoadText(strings.NewReader(input), 0, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tree.Len())
+	assert.Equal(t, []Interval[int, any]{{10, 20, ""}, {15, 30, "gene  BRCA1   plus"}}, tree.Query(17))
+	assert.Equal(t, []Interval[int, any]{{40, 50, "exon"}}, tree.Query(45))
+}
+
+func TestLoadTextErrors(t *testing.T) {
+	_, err := LoadText(strings.NewReader("10 20\n30\n"), 0, 100)
+	assert.EqualError(t, err, "line 2: expected at least start and end fields")
+	_, err = LoadText(strings.NewReader("10 20\n\n30 x\n"), 0, 100)
+	assert.EqualError(t, err, `line 3: strconv.ParseInt: parsing "x": invalid syntax`)
+	_, err = LoadText(strings.NewReader("30 20\n"), 0, 100)
+	assert.EqualError(t, err, "line 1: interval start must be numerically less than its end")
+	_, err = LoadText(strings.NewReader("10 300\n"), int8(0), int8(100))
+	assert.EqualError(t, err, "line 1: coordinate 300 is out of range")
+	_, err = LoadText(strings.NewReader(""), 10, 0)
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestIntervalTree_ExportImport(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(32, 38, nil)
+	_ = tree.AddInterval(1, 10, []string{"a", "b"})
+	_ = tree.AddInterval(20, 30, 7)
+	_ = tree.AddInterval(32, 35, "x")
+	tree.Sort()
+	records := tree.Export()
+	assert.Equal(t, []ExportedInterval[int, any]{
+		{1, 10, []string{"a", "b"}}, {20, 30, 7}, {32, 35, "x"}, {32, 38, nil},
+	}, records)
+	restored, err := Import(0, 100, records)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, tree.Iter(), restored.Iter())
+	assert.Equal(t, tree.Query(33), restored.Query(33))
+}
+
+func TestImportErrors(t *testing.T) {
+	_, err := Import(0, 100, []ExportedInterval[int, any]{{1, 10, nil}, {10, 1, nil}})
+	assert.EqualError(t, err, "record 1: interval start must be numerically less than its end")
+	_, err = Import[int, any](100, 0, nil)
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestIntervalTree_ToFrontendJSON(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	var buffer bytes.Buffer
+	assert.NoError(t, tree.ToFrontendJSON(&buffer))
+	assert.Equal(t, "[]", buffer.String())
+	_ = tree.AddInterval(32, 38, nil)
+	_ = tree.AddInterval(1, 10, []string{"a", "b"})
+	_ = tree.AddInterval(20, 30, map[string]int{"x": 1})
+	tree.Sort()
+	buffer.Reset()
+	assert.NoError(t, tree.ToFrontendJSON(&buffer))
+	assert.Equal(t, `[{"s":1,"e":10,"d":["a","b"]},{"s":20,"e":30,"d":{"x":1}},{"s":32,"e":38,"d":null}]`, buffer.String())
+	var records []map[string]any
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &records))
+	assert.Len(t, records, tree.Len())
+
+	_ = tree.AddInterval(40, 50, func() {})
+	assert.Error(t, tree.ToFrontendJSON(&buffer))
+}
+
+func TestFromMap(t *testing.T) {
+	tree, err := FromMap(0, 100, map[[2]int]any{{10, 20}: "a", {15, 30}: "b", {50, 60}: nil})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tree.Len())
+	assert.ElementsMatch(t, []Interval[int, any]{{10, 20, "a"}, {15, 30, "b"}}, tree.Query(17))
+	assert.Equal(t, []Interval[int, any]{{50, 60, nil}}, tree.Query(55))
+
+	tree, err = FromMap(0, 100, map[[2]int]any{{10, 20}: "a", {30, 30}: "b", {50, 40}: "c"})
+	assert.EqualError(t, err, "range [30 30]: interval start must be numerically less than its end\n"+
+		"range [50 40]: interval start must be numerically less than its end")
+	assert.Equal(t, 1, tree.Len())
+	_, err = FromMap[int, any](10, 0, nil)
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestBuildFromFunc(t *testing.T) {
+	n := 0
+	tree, err := BuildFromFunc(0, 1000, func() (int, int, any, bool) {
+		if n == 100 {
+			return 0, 0, nil, false
+		}
+		n++
+		return n * 5, n*5 + 10, n, true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, tree.Len())
+	assert.ElementsMatch(t, []Interval[int, any]{{45, 55, 9}, {50, 60, 10}}, tree.Query(52))
+
+	n = 0
+	tree, err = BuildFromFunc(0, 1000, func() (int, int, any, bool) {
+		n++
+		switch n {
+		case 1:
+			return 10, 20, nil, true
+		case 2:
+			return 30, 20, nil, true
+		case 3:
+			return 40, 50, nil, true
+		case 4:
+			return 60, 60, nil, true
+		}
+		return 0, 0, nil, false
+	})
+	assert.EqualError(t, err, "interval 1: interval start must be numerically less than its end\n"+
+		"interval 3: interval start must be numerically less than its end")
+	assert.Equal(t, 2, tree.Len())
+	_, err = BuildFromFunc(10, 0, func() (int, int, any, bool) { return 0, 0, nil, false })
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestIntervalTree_JSONRoundTrip(t *testing.T) {
+	type rule struct {
+		Route  string `json:"route"`
+		Weight int    `json:"weight"`
+	}
+	tree, _ := NewTypedIntervalTree[int, rule](0, 100)
+	for _, i := range [][]int{{1, 10}, {10, 20}, {20, 30}, {21, 31}, {45, 55}, {46, 57}, {5, 95}} {
+		_ = tree.AddInterval(i[0], i[1], rule{Route: "r" + strconv.Itoa(i[0]), Weight: i[1]})
+	}
+	tree.Sort()
+	encoded, err := json.Marshal(tree)
+	assert.NoError(t, err)
+	restored, _ := NewTypedIntervalTree[int, rule](0, 1)
+	assert.NoError(t, json.Unmarshal(encoded, restored))
+	assert.Equal(t, tree.Iter(), restored.Iter())
+	assert.Equal(t, tree.Query(50), restored.Query(50))
+
+	untyped, _ := NewIntervalTree(0, 1)
+	assert.NoError(t, json.Unmarshal([]byte(`{"min":0,"max":10,"intervals":[{"start":2,"end":4,"data":"a"},{"start":3,"end":5,"data":1}]}`), untyped))
+	assert.Equal(t, []Interval[int, any]{{2, 4, "a"}, {3, 5, 1.0}}, untyped.Query(3))
+	encoded, err = json.Marshal(untyped)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"min":0,"max":10,"intervals":[{"start":3,"end":5,"data":1},{"start":2,"end":4,"data":"a"}]}`, string(encoded))
+}
+
+func TestIntervalTree_UnmarshalJSONErrors(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(1, 10, nil)
+	assert.EqualError(t, json.Unmarshal([]byte(`{"min":10,"max":0}`), tree), "interval tree start must be numerically less than its end")
+	assert.EqualError(t, json.Unmarshal([]byte(`{"min":0,"max":10,"intervals":[{"start":1,"end":2},{"start":5,"end":5}]}`), tree),
+		"interval 1: interval start must be numerically less than its end")
+	assert.Error(t, json.Unmarshal([]byte(`{"min":"a"}`), tree))
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestLoadTextCoordinateKinds(t *testing.T) {
+	floats, err := LoadText(strings.NewReader("0.5 1.25 a\n1e-1 2.5e0\n"), 0.0, 10.0)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Interval[float64, any]{{0.5, 1.25, "a"}, {0.1, 2.5, ""}}, floats.Query(1))
+	_, err = LoadText(strings.NewReader("0 1e39\n"), float32(0), float32(10))
+	assert.EqualError(t, err, "line 1: coordinate 1e39 is out of range")
+
+	unsigned, err := LoadText(strings.NewReader("4000000000 4294967295\n"), uint32(0), uint32(math.MaxUint32))
+	assert.NoError(t, err)
+	assert.Equal(t, []Interval[uint32, any]{{4000000000, 4294967295, ""}}, unsigned.Query(4100000000))
+	_, err = LoadText(strings.NewReader("0 4294967296\n"), uint32(0), uint32(math.MaxUint32))
+	assert.EqualError(t, err, "line 1: coordinate 4294967296 is out of range")
+	_, err = LoadText(strings.NewReader("-1 10\n"), uint32(0), uint32(100))
+	assert.EqualError(t, err, `line 1: strconv.ParseUint: parsing "-1": invalid syntax`)
+	_, err = LoadText(strings.NewReader("1.5 10\n"), 0, 100)
+	assert.EqualError(t, err, `line 1: strconv.ParseInt: parsing "1.5": invalid syntax`)
+}
+
+func TestBuildFromIntervals(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for dataset := 0; dataset < 5; dataset++ {
+		offset := random.Intn(2000) - 1000
+		var intervals []Interval[int, any]
+		for i := 0; i < 200; i++ {
+			start := offset + random.Intn(500)
+			intervals = append(intervals, NewInterval[int, any](start, start+1+random.Intn(100), i))
+		}
+		tree, err := BuildFromIntervals(intervals)
+		assert.NoError(t, err)
+		assert.Equal(t, len(intervals), tree.Len())
+		for x := offset - 5; x < offset+610; x++ {
+			var expected []Interval[int, any]
+			for _, i := range intervals {
+				if i.start <= x && x < i.end {
+					expected = append(expected, i)
+				}
+			}
+			assert.ElementsMatch(t, expected, tree.Query(x), "dataset %d, point %d", dataset, x)
+		}
+	}
+
+	tree, err := BuildFromIntervals([]Interval[int, string]{{10, 20, "a"}, {-5, 12, "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, -5, tree.min)
+	assert.Equal(t, 20, tree.max)
+	_, err = BuildFromIntervals([]Interval[int, any]{{10, 20, nil}, {30, 30, nil}})
+	assert.EqualError(t, err, "interval 1: interval start must be numerically less than its end")
+	_, err = BuildFromIntervals[int, any](nil)
+	assert.EqualError(t, err, "at least one interval is required to build a tree")
+}
+
+func TestIntervalTree_GobRoundTrip(t *testing.T) {
+	type rule struct {
+		Route  string
+		Weight int
+	}
+	tree, _ := NewTypedIntervalTree[int, rule](0, 100)
+	for _, i := range [][]int{{1, 10}, {10, 20}, {20, 30}, {21, 31}, {45, 55}, {46, 57}, {5, 95}} {
+		_ = tree.AddInterval(i[0], i[1], rule{Route: "r" + strconv.Itoa(i[0]), Weight: i[1]})
+	}
+	tree.Sort()
+	var buffer bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buffer).Encode(tree))
+	restored, _ := NewTypedIntervalTree[int, rule](0, 1)
+	assert.NoError(t, gob.NewDecoder(&buffer).Decode(restored))
+	assert.Equal(t, tree.Iter(), restored.Iter())
+	assert.Equal(t, tree.Query(50), restored.Query(50))
+
+	gob.Register(rule{})
+	untyped, _ := NewIntervalTree(-10.0, 10.0)
+	_ = untyped.AddInterval(-5, 5, rule{"a", 1})
+	_ = untyped.AddInterval(1, 2, "b")
+	_ = untyped.AddInterval(2, 3, nil)
+	untyped.Sort()
+	encoded, err := untyped.GobEncode()
+	assert.NoError(t, err)
+	decoded, _ := NewIntervalTree(0.0, 1.0)
+	assert.NoError(t, decoded.GobDecode(encoded))
+	assert.Equal(t, untyped.Iter(), decoded.Iter())
+	assert.Equal(t, -10.0, decoded.min)
+
+	assert.Error(t, decoded.GobDecode([]byte("garbage")))
+	assert.Equal(t, 3, decoded.Len())
+}
+
+func TestIntervalTree_CSVRoundTrip(t *testing.T) {
+	input := "10,20,gene\n5,30,\"exon, long\"\n15,16\n"
+	parseLabel := func(fields []string) (string, error) {
+		return strings.Join(fields, ";"), nil
+	}
+	tree, err := LoadCSV[int](strings.NewReader(input), parseLabel)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tree.Len())
+	assert.Equal(t, []int{5, 30}, []int{tree.Min(), tree.Max()})
+	assert.ElementsMatch(t, []Interval[int, string]{{5, 30, "exon, long"}, {10, 20, "gene"}, {15, 16, ""}}, tree.Query(15))
+
+	var buffer bytes.Buffer
+	formatLabel := func(data string) []string {
+		if data == "" {
+			return nil
+		}
+		return []string{data}
+	}
+	assert.NoError(t, tree.WriteCSV(&buffer, formatLabel))
+	assert.Equal(t, "5,30,\"exon, long\"\n10,20,gene\n15,16\n", buffer.String())
+	restored, err := LoadCSV[int](bytes.NewReader(buffer.Bytes()), parseLabel)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.IterSorted(), restored.IterSorted())
+
+	floats, _ := NewTypedIntervalTree[float64, int](0, 10)
+	_ = floats.AddInterval(0.25, 1e-3+2, 7)
+	buffer.Reset()
+	assert.NoError(t, floats.WriteCSV(&buffer, func(data int) []string { return []string{strconv.Itoa(data)} }))
+	restoredFloats, err := LoadCSV[float64](&buffer, func(fields []string) (int, error) { return strconv.Atoi(fields[0]) })
+	assert.NoError(t, err)
+	assert.Equal(t, floats.Iter(), restoredFloats.Iter())
+}
+
+func TestLoadCSVErrors(t *testing.T) {
+	ignore := func(fields []string) (any, error) { return nil, nil }
+	for input, expected := range map[string]string{
+		"":                      "at least one interval is required",
+		"1,2\n3\n":              "line 2: expected at least start and end fields",
+		"1,2\n\n3,x\n":          "line 3: strconv.ParseInt",
+		"1,2\n5,5\n":            "line 2: interval start must be numerically less than its end",
+		"1,2\n3,4,\"unclosed\n": "line 2",
+	} {
+		_, err := LoadCSV[int](strings.NewReader(input), ignore)
+		if assert.Error(t, err, input) {
+			assert.Contains(t, err.Error(), expected, input)
+		}
+	}
+	_, err := LoadCSV[int](strings.NewReader("1,2,bad\n"), func(fields []string) (any, error) {
+		return nil, errors.New("bad label")
+	})
+	assert.EqualError(t, err, "line 1: bad label")
+}
+
+func TestIntervalTree_EncodingClosedIntervals(t *testing.T) {
+	tree, _ := NewTypedIntervalTree[int, string](0, 100, WithClosedIntervals())
+	_ = tree.AddInterval(5, 5, "point")
+	_ = tree.AddInterval(10, 20, "a")
+	tree.Sort()
+	encoded, err := json.Marshal(tree)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"closed":true`)
+	var fromJSON IntervalTree[int, string]
+	assert.NoError(t, json.Unmarshal(encoded, &fromJSON))
+	assert.Equal(t, []Interval[int, string]{{5, 5, "point"}}, fromJSON.Query(5))
+	assert.Len(t, fromJSON.Query(20), 1)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buffer).Encode(tree))
+	var fromGob IntervalTree[int, string]
+	assert.NoError(t, gob.NewDecoder(&buffer).Decode(&fromGob))
+	assert.Equal(t, tree.IterSorted(), fromGob.IterSorted())
+	assert.Len(t, fromGob.Query(20), 1)
+
+	halfOpen, _ := NewTypedIntervalTree[int, string](0, 10)
+	encoded, _ = json.Marshal(halfOpen)
+	assert.NotContains(t, string(encoded), "closed")
+	assert.NoError(t, json.Unmarshal(encoded, &fromJSON))
+	assert.False(t, fromJSON.options.closed)
+}
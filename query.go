@@ -0,0 +1,956 @@
+package gointervaltree
+
+import (
+	"cmp"
+	"errors"
+	"iter"
+	"slices"
+	"sort"
+)
+
+// stabbingCursor walks a slice of intervals sorted by start and yields those overlapping x.
+type stabbingCursor[T Coordinate, D any] struct {
+	intervals []*interval[T, D]
+	position  int
+	x         T
+	closed    bool
+}
+
+// head method returns the next interval of the cursor overlapping x or nil when the cursor is exhausted.
+func (c *stabbingCursor[T, D]) head() *interval[T, D] {
+	for c.position < len(c.intervals) && (c.intervals[c.position].end < c.x || (!c.closed && c.intervals[c.position].end == c.x)) {
+		c.position++
+	}
+	if c.position == len(c.intervals) || c.intervals[c.position].start > c.x {
+		return nil
+	}
+	return c.intervals[c.position]
+}
+
+// visitQuery method calls fn for every interval overlapping x in the same order as Query and stops as soon as fn
+// returns false. It reports whether the traversal ran to completion.
+func (tree *intervalTree[T, D]) visitQuery(x T, fn func(i *interval[T, D]) bool) bool {
+	if tree.singleInterval == nil {
+		return true
+	} else if !tree.singleInterval.blocked {
+		if tree.singleInterval.start <= x && !tree.endsBefore(tree.singleInterval.end, x) {
+			return fn(tree.singleInterval)
+		}
+		return true
+	} else if tree.flat() {
+		for _, element := range tree.midSortedByStart {
+			if element.start > x {
+				break
+			}
+			if !tree.endsBefore(element.end, x) && !fn(element) {
+				return false
+			}
+		}
+		return true
+	} else if x < tree.center {
+		if tree.leftSubtree != nil && !tree.leftSubtree.visitQuery(x, fn) {
+			return false
+		}
+		for _, element := range tree.midSortedByStart {
+			if element.start > x {
+				break
+			}
+			if !fn(element) {
+				return false
+			}
+		}
+		return true
+	} else {
+		for _, element := range tree.midSortedByEnd {
+			if tree.endsBefore(element.end, x) {
+				break
+			}
+			if !fn(element) {
+				return false
+			}
+		}
+		if tree.rightSubtree != nil {
+			return tree.rightSubtree.visitQuery(x, fn)
+		}
+		return true
+	}
+}
+
+// QueryCentroid method returns the length-weighted mean of midpoints of all intervals overlapping x, ok is false
+// if no interval overlaps x. Midpoints and lengths are computed in float64 so large coordinates cannot overflow.
+func (tree *intervalTree[T, D]) QueryCentroid(x T) (centroid float64, ok bool) {
+	var weightedSum, totalWeight float64
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		start, end := float64(i.start), float64(i.end)
+		length := end - start
+		weightedSum += length * (start/2 + end/2)
+		totalWeight += length
+		return true
+	})
+	if totalWeight == 0 {
+		return 0, false
+	}
+	return weightedSum / totalWeight, true
+}
+
+// QuerySortedSeq method returns an iterator over all intervals overlapping x in ascending order of start.
+// Intervals are produced lazily by a k-way merge of the per-node contributions along the query path,
+// so breaking out early does not sort or materialize the whole result.
+func (tree *intervalTree[T, D]) QuerySortedSeq(x T) iter.Seq[Interval[T, D]] {
+	return func(yield func(Interval[T, D]) bool) {
+		var cursors []*stabbingCursor[T, D]
+		for node := tree; node != nil && node.singleInterval != nil; {
+			if !node.singleInterval.blocked {
+				cursors = append(cursors, &stabbingCursor[T, D]{intervals: []*interval[T, D]{node.singleInterval}, x: x, closed: node.options.closed})
+				break
+			}
+			cursors = append(cursors, &stabbingCursor[T, D]{intervals: node.midSortedByStart, x: x, closed: node.options.closed})
+			if x < node.center {
+				node = node.leftSubtree
+			} else {
+				node = node.rightSubtree
+			}
+		}
+		for {
+			var best *stabbingCursor[T, D]
+			for _, c := range cursors {
+				if h := c.head(); h != nil && (best == nil || h.start < best.head().start) {
+					best = c
+				}
+			}
+			if best == nil {
+				return
+			}
+			element := best.head()
+			best.position++
+			if !yield(Interval[T, D]{start: element.start, end: element.end, data: element.data}) {
+				return
+			}
+		}
+	}
+}
+
+// extent method returns the smallest start and the largest end among intervals maintained in the tree,
+// ok is false if the tree is empty.
+func (tree *intervalTree[T, D]) extent() (start, end T, ok bool) {
+	for _, element := range tree.Iter() {
+		if !ok || element.start < start {
+			start = element.start
+		}
+		if !ok || element.end > end {
+			end = element.end
+		}
+		ok = true
+	}
+	return start, end, ok
+}
+
+// QueryableRange method returns the range [min, max) over which Query is guaranteed to return exactly the intervals
+// a brute-force scan would. Intervals are not required to lie within the tree bounds, those beyond them are kept
+// at the outermost nodes, so the range is the union of the tree bounds and the extent of stored intervals. Outside
+// of it no stored interval can overlap a point and Query returns no results.
+func (tree *intervalTree[T, D]) QueryableRange() (min, max T) {
+	min, max = tree.min, tree.max
+	if start, end, ok := tree.extent(); ok {
+		if start < min {
+			min = start
+		}
+		if end > max {
+			max = end
+		}
+	}
+	return min, max
+}
+
+// visitRange method calls fn for every interval overlapping [start, end) and stops as soon as fn returns false.
+// Subtrees which cannot hold overlapping intervals are pruned. It reports whether the traversal ran to completion.
+func (tree *intervalTree[T, D]) visitRange(start, end T, fn func(i *interval[T, D]) bool) bool {
+	return tree.visitSpan(start, end, false, fn)
+}
+
+// visitSpan method is a technical method used inside visitRange, with closed set it visits intervals overlapping
+// [start, end] as closed intervals instead, which is only valid for a tree created WithClosedIntervals.
+func (tree *intervalTree[T, D]) visitSpan(start, end T, closed bool, fn func(i *interval[T, D]) bool) bool {
+	overlaps := func(i *interval[T, D]) bool {
+		if closed {
+			return i.start <= end && start <= i.end
+		}
+		return i.start < end && start < i.end
+	}
+	if tree.singleInterval == nil {
+		return true
+	} else if !tree.singleInterval.blocked {
+		if overlaps(tree.singleInterval) {
+			return fn(tree.singleInterval)
+		}
+		return true
+	}
+	// left subtree holds intervals with end <= center, right subtree holds intervals with start > center
+	if start < tree.center && tree.leftSubtree != nil && !tree.leftSubtree.visitSpan(start, end, closed, fn) {
+		return false
+	}
+	for _, element := range tree.midSortedByStart {
+		if overlaps(element) && !fn(element) {
+			return false
+		}
+	}
+	if end > tree.center && tree.rightSubtree != nil {
+		return tree.rightSubtree.visitSpan(start, end, closed, fn)
+	}
+	return true
+}
+
+// QueryCount method returns the number of intervals in the tree which overlap given point, i.e. len(tree.Query(x)),
+// walking the same recursion as Query without allocating a result slice.
+func (tree *intervalTree[T, D]) QueryCount(x T) int {
+	if tree.singleInterval == nil {
+		return 0
+	} else if !tree.singleInterval.blocked {
+		if tree.singleInterval.start <= x && !tree.endsBefore(tree.singleInterval.end, x) {
+			return 1
+		}
+		return 0
+	} else if tree.flat() {
+		count := 0
+		for _, element := range tree.midSortedByStart {
+			if element.start > x {
+				break
+			}
+			if !tree.endsBefore(element.end, x) {
+				count++
+			}
+		}
+		return count
+	} else if x < tree.center {
+		count := 0
+		if tree.leftSubtree != nil {
+			count += tree.leftSubtree.QueryCount(x)
+		}
+		for _, element := range tree.midSortedByStart {
+			if element.start > x {
+				break
+			}
+			count++
+		}
+		return count
+	} else {
+		count := 0
+		for _, element := range tree.midSortedByEnd {
+			if tree.endsBefore(element.end, x) {
+				break
+			}
+			count++
+		}
+		if tree.rightSubtree != nil {
+			count += tree.rightSubtree.QueryCount(x)
+		}
+		return count
+	}
+}
+
+// QueryRange method returns all intervals in the tree which overlap [start, end), i.e. all records for which
+// (record.start < end && start < record.end). It follows the center split of the tree and skips subtrees which
+// cannot hold overlapping intervals.
+func (tree *intervalTree[T, D]) QueryRange(start, end T) ([]Interval[T, D], error) {
+	if !(start < end) {
+		return nil, errors.New("query start must be numerically less than its end")
+	}
+	var result []Interval[T, D]
+	tree.visitRange(start, end, func(i *interval[T, D]) bool {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		return true
+	})
+	return result, nil
+}
+
+// QueryRangeCount method returns the number of intervals in the tree which overlap [start, end), i.e.
+// len(tree.QueryRange(start, end)), walking the same pruned traversal as QueryRange without allocating a result slice.
+func (tree *intervalTree[T, D]) QueryRangeCount(start, end T) (int, error) {
+	if !(start < end) {
+		return 0, errors.New("query start must be numerically less than its end")
+	}
+	count := 0
+	tree.visitRange(start, end, func(i *interval[T, D]) bool {
+		count++
+		return true
+	})
+	return count, nil
+}
+
+// QueryFrom method returns all intervals in the tree which overlap [x, +inf), i.e. all records with (x < end).
+func (tree *intervalTree[T, D]) QueryFrom(x T) []Interval[T, D] {
+	var result []Interval[T, D]
+	if tree.singleInterval == nil {
+		return result
+	} else if !tree.singleInterval.blocked {
+		if x < tree.singleInterval.end {
+			result = append(result, Interval[T, D]{start: tree.singleInterval.start, end: tree.singleInterval.end, data: tree.singleInterval.data})
+		}
+		return result
+	}
+	// left subtree holds intervals with end <= center
+	if x < tree.center && tree.leftSubtree != nil {
+		result = append(result, tree.leftSubtree.QueryFrom(x)...)
+	}
+	for _, element := range tree.midSortedByEnd {
+		if element.end <= x {
+			break
+		}
+		result = append(result, Interval[T, D]{start: element.start, end: element.end, data: element.data})
+	}
+	if tree.rightSubtree != nil {
+		result = append(result, tree.rightSubtree.QueryFrom(x)...)
+	}
+	return result
+}
+
+// QueryUntil method returns all intervals in the tree which overlap (-inf, x), i.e. all records with (start < x).
+func (tree *intervalTree[T, D]) QueryUntil(x T) []Interval[T, D] {
+	var result []Interval[T, D]
+	if tree.singleInterval == nil {
+		return result
+	} else if !tree.singleInterval.blocked {
+		if tree.singleInterval.start < x {
+			result = append(result, Interval[T, D]{start: tree.singleInterval.start, end: tree.singleInterval.end, data: tree.singleInterval.data})
+		}
+		return result
+	}
+	if tree.leftSubtree != nil {
+		result = append(result, tree.leftSubtree.QueryUntil(x)...)
+	}
+	for _, element := range tree.midSortedByStart {
+		if element.start >= x {
+			break
+		}
+		result = append(result, Interval[T, D]{start: element.start, end: element.end, data: element.data})
+	}
+	// right subtree holds intervals with start > center
+	if x > tree.center && tree.rightSubtree != nil {
+		result = append(result, tree.rightSubtree.QueryUntil(x)...)
+	}
+	return result
+}
+
+// WeightedDepth method returns the sum of weights of all intervals overlapping x in a single traversal,
+// where weight maps interval data to its numeric weight.
+func (tree *intervalTree[T, D]) WeightedDepth(x T, weight func(data D) float64) float64 {
+	var depth float64
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		depth += weight(i.data)
+		return true
+	})
+	return depth
+}
+
+// QueryCollapsed method returns all intervals overlapping x grouped by identical (start, end) bounds, every group
+// collecting the data of its intervals. Groups are sorted by start and then by end.
+func (tree *intervalTree[T, D]) QueryCollapsed(x T) []struct {
+	Start, End T
+	Data       []D
+} {
+	var result []struct {
+		Start, End T
+		Data       []D
+	}
+	matches := tree.Query(x)
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end < matches[j].end
+	})
+	for _, i := range matches {
+		if n := len(result); n > 0 && result[n-1].Start == i.start && result[n-1].End == i.end {
+			result[n-1].Data = append(result[n-1].Data, i.data)
+			continue
+		}
+		result = append(result, struct {
+			Start, End T
+			Data       []D
+		}{i.start, i.end, []D{i.data}})
+	}
+	return result
+}
+
+// compareLength method returns -1, 0 or +1 as the length of the interval is less than, equal to or greater than
+// the length of other. Lengths of signed integer intervals may not fit into T, e.g. [-100, 100) in int8, so they are
+// compared as halves of both bounds plus the remainders of the halving rather than by subtracting the bounds.
+func (i *interval[T, D]) compareLength(other *interval[T, D]) int {
+	var zero T
+	if isFloat[T]() || zero-1 > 0 { // lengths of unsigned intervals cannot overflow
+		return cmp.Compare(i.end-i.start, other.end-other.start)
+	}
+	halves := func(start, end T) (half, rest T) {
+		return end/2 - start/2, (end - end/2*2) - (start - start/2*2)
+	}
+	iHalf, iRest := halves(i.start, i.end)
+	otherHalf, otherRest := halves(other.start, other.end)
+	// rests lie within [-2, 2], so halves further apart than 2 decide on their own
+	diff := iHalf - otherHalf
+	switch {
+	case diff > 2:
+		return 1
+	case -diff > 2:
+		return -1
+	}
+	return cmp.Compare(2*diff+iRest-otherRest, 0)
+}
+
+// QueryInnermost method returns the shortest interval overlapping x, i.e. the innermost one when intervals are nested,
+// ties on length are broken by the latest start. ok is false if no interval overlaps x.
+func (tree *intervalTree[T, D]) QueryInnermost(x T) (result Interval[T, D], ok bool) {
+	var best *interval[T, D]
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		if best == nil {
+			best = i
+		} else if c := i.compareLength(best); c < 0 || (c == 0 && i.start > best.start) {
+			best = i
+		}
+		return true
+	})
+	if best == nil {
+		return result, false
+	}
+	return Interval[T, D]{start: best.start, end: best.end, data: best.data}, true
+}
+
+// QueryOutermost method returns the longest interval overlapping x, i.e. the outermost one when intervals are nested,
+// ties on length are broken by the earliest start. ok is false if no interval overlaps x.
+func (tree *intervalTree[T, D]) QueryOutermost(x T) (result Interval[T, D], ok bool) {
+	var best *interval[T, D]
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		if best == nil {
+			best = i
+		} else if c := i.compareLength(best); c > 0 || (c == 0 && i.start < best.start) {
+			best = i
+		}
+		return true
+	})
+	if best == nil {
+		return result, false
+	}
+	return Interval[T, D]{start: best.start, end: best.end, data: best.data}, true
+}
+
+// QueryBin method returns all intervals overlapping the unit bin [x, x+1). For integer coordinates under half-open
+// semantics an interval overlaps the bin exactly when it contains x, so the result matches Query(x) as a set.
+// If x+1 is not representable the result of Query(x) is returned.
+func (tree *intervalTree[T, D]) QueryBin(x T) []Interval[T, D] {
+	if x+1 <= x {
+		return tree.Query(x)
+	}
+	var result []Interval[T, D]
+	tree.visitRange(x, x+1, func(i *interval[T, D]) bool {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		return true
+	})
+	return result
+}
+
+// Transition method returns how the set of intervals overlapping x changes when moving to x+1. Under half-open
+// semantics an interval [start, end) with start == x+1 is gained and one with end == x+1 is lost, while an interval
+// covering both points is neither. For a WithClosedIntervals tree an interval [start, end] is lost with end == x,
+// as it still covers its end. The opposite move from x+1 to x swaps gained and lost.
+// If x+1 is not representable both results are empty.
+func (tree *intervalTree[T, D]) Transition(x T) (gained, lost []Interval[T, D]) {
+	next := x + 1
+	if next <= x {
+		return nil, nil
+	}
+	lastCovered := next
+	if tree.options.closed {
+		lastCovered = x
+	}
+	tree.visitQuery(next, func(i *interval[T, D]) bool {
+		if i.start == next {
+			gained = append(gained, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		}
+		return true
+	})
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		if i.end == lastCovered {
+			lost = append(lost, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		}
+		return true
+	})
+	return gained, lost
+}
+
+// QueryDistinct method returns intervals overlapping x keeping at most one interval per distinct data key computed
+// by key. Of intervals sharing a key the longest one is kept, ties are broken by the earliest start.
+// Results are sorted by start and then by end.
+func (tree *intervalTree[T, D]) QueryDistinct(x T, key func(data D) string) []Interval[T, D] {
+	kept := make(map[string]*interval[T, D])
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		k := key(i.data)
+		best, ok := kept[k]
+		if !ok {
+			kept[k] = i
+		} else if c := i.compareLength(best); c > 0 || (c == 0 && i.start < best.start) {
+			kept[k] = i
+		}
+		return true
+	})
+	var result []Interval[T, D]
+	for _, i := range kept {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].start != result[j].start {
+			return result[i].start < result[j].start
+		}
+		return result[i].end < result[j].end
+	})
+	return result
+}
+
+// QueryEpsilon method returns all intervals overlapping x with a tolerance of eps, i.e. all records for which
+// (start-eps <= x < end+eps) holds in float64 arithmetic. It absorbs rounding errors at interval boundaries, but
+// widens every interval by eps on both sides, so a point within eps of the boundary between adjacent intervals
+// matches both of them.
+func (tree *intervalTree[T, D]) QueryEpsilon(x, eps float64) []Interval[T, D] {
+	var result []Interval[T, D]
+	stabbed := func(i *interval[T, D]) bool {
+		return float64(i.start)-eps <= x && x < float64(i.end)+eps
+	}
+	if tree.singleInterval == nil {
+		return result
+	} else if !tree.singleInterval.blocked {
+		if stabbed(tree.singleInterval) {
+			result = append(result, Interval[T, D]{start: tree.singleInterval.start, end: tree.singleInterval.end, data: tree.singleInterval.data})
+		}
+		return result
+	}
+	center := float64(tree.center)
+	// left subtree holds intervals with end <= center, right subtree holds intervals with start > center
+	if x < center+eps && tree.leftSubtree != nil {
+		result = append(result, tree.leftSubtree.QueryEpsilon(x, eps)...)
+	}
+	for _, element := range tree.midSortedByStart {
+		if stabbed(element) {
+			result = append(result, Interval[T, D]{start: element.start, end: element.end, data: element.data})
+		}
+	}
+	if x > center-eps && tree.rightSubtree != nil {
+		result = append(result, tree.rightSubtree.QueryEpsilon(x, eps)...)
+	}
+	return result
+}
+
+// QueryWithPosition method returns all intervals overlapping x in Query order, each with the fraction of the interval
+// lying before x, i.e. (x - start) / (end - start) computed in float64. Degenerate intervals report zero.
+func (tree *intervalTree[T, D]) QueryWithPosition(x T) []struct {
+	Interval       Interval[T, D]
+	FractionBefore float64
+} {
+	var result []struct {
+		Interval       Interval[T, D]
+		FractionBefore float64
+	}
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		fraction := 0.0
+		if length := float64(i.end) - float64(i.start); length > 0 {
+			fraction = (float64(x) - float64(i.start)) / length
+		}
+		result = append(result, struct {
+			Interval       Interval[T, D]
+			FractionBefore float64
+		}{Interval[T, D]{start: i.start, end: i.end, data: i.data}, fraction})
+		return true
+	})
+	return result
+}
+
+// QueryFunc method calls fn for every interval overlapping x in the same order as Query without allocating
+// a result slice. Traversal stops as soon as fn returns false, no further mid-lists or subtrees are visited.
+func (tree *intervalTree[T, D]) QueryFunc(x T, fn func(start, end T, data D) bool) {
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		return fn(i.start, i.end, i.data)
+	})
+}
+
+// All method returns an iterator over all intervals maintained in the tree in Iter order. Intervals are produced
+// while walking the tree, so breaking out early skips the rest of the walk.
+func (tree *intervalTree[T, D]) All() iter.Seq[Interval[T, D]] {
+	return func(yield func(Interval[T, D]) bool) {
+		tree.visitAll(func(i *interval[T, D]) bool {
+			return yield(Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		})
+	}
+}
+
+// Overlapping method returns an iterator over all intervals overlapping x in Query order. Intervals are produced
+// while walking the query path, so breaking out early skips the remaining mid-lists and subtrees.
+func (tree *intervalTree[T, D]) Overlapping(x T) iter.Seq[Interval[T, D]] {
+	return func(yield func(Interval[T, D]) bool) {
+		tree.visitQuery(x, func(i *interval[T, D]) bool {
+			return yield(Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		})
+	}
+}
+
+// QueryContaining method returns all intervals in the tree which fully contain [start, end), i.e. all intervals with
+// (i.start <= start && end <= i.end). Only intervals straddling the center of a node or lying on the side of the
+// center holding the whole span can contain it, so at most one subtree of every node is visited.
+// An empty span yields no results.
+func (tree *intervalTree[T, D]) QueryContaining(start, end T) []Interval[T, D] {
+	var result []Interval[T, D]
+	if !(start < end) {
+		return result
+	}
+	tree.visitContaining(start, end, func(i *interval[T, D]) {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+	})
+	return result
+}
+
+// visitContaining method is a technical method used inside QueryContaining.
+func (tree *intervalTree[T, D]) visitContaining(start, end T, fn func(i *interval[T, D])) {
+	if tree.singleInterval == nil {
+		return
+	} else if !tree.singleInterval.blocked {
+		if tree.singleInterval.start <= start && end <= tree.singleInterval.end {
+			fn(tree.singleInterval)
+		}
+		return
+	}
+	// left subtree holds intervals with end <= center, right subtree holds intervals with start > center
+	if end <= tree.center && tree.leftSubtree != nil {
+		tree.leftSubtree.visitContaining(start, end, fn)
+	}
+	for _, element := range tree.midSortedByStart {
+		if element.start <= start && end <= element.end {
+			fn(element)
+		}
+	}
+	if start > tree.center && tree.rightSubtree != nil {
+		tree.rightSubtree.visitContaining(start, end, fn)
+	}
+}
+
+// QueryContainedIn method returns all intervals in the tree which lie fully inside [start, end), i.e. all intervals
+// with (start <= i.start && i.end <= end). Subtrees on a side of the center the span does not reach are pruned and
+// intervals straddling the center are inspected only if the span contains the center. An empty span yields
+// no results.
+func (tree *intervalTree[T, D]) QueryContainedIn(start, end T) []Interval[T, D] {
+	var result []Interval[T, D]
+	if !(start < end) {
+		return result
+	}
+	tree.visitContainedIn(start, end, func(i *interval[T, D]) {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+	})
+	return result
+}
+
+// visitContainedIn method is a technical method used inside QueryContainedIn.
+func (tree *intervalTree[T, D]) visitContainedIn(start, end T, fn func(i *interval[T, D])) {
+	if tree.singleInterval == nil {
+		return
+	} else if !tree.singleInterval.blocked {
+		if start <= tree.singleInterval.start && tree.singleInterval.end <= end {
+			fn(tree.singleInterval)
+		}
+		return
+	}
+	// left subtree holds intervals with end <= center, right subtree holds intervals with start > center
+	if start < tree.center && tree.leftSubtree != nil {
+		tree.leftSubtree.visitContainedIn(start, end, fn)
+	}
+	if tree.flat() || (start <= tree.center && tree.center <= end) {
+		for _, element := range tree.midSortedByStart {
+			if start <= element.start && element.end <= end {
+				fn(element)
+			}
+		}
+	}
+	if end > tree.center && tree.rightSubtree != nil {
+		tree.rightSubtree.visitContainedIn(start, end, fn)
+	}
+}
+
+// Overlaps method reports whether any interval in the tree overlaps given point, i.e. len(tree.Query(x)) > 0.
+// The walk stops at the first overlapping interval.
+func (tree *intervalTree[T, D]) Overlaps(x T) bool {
+	found := false
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// QueryBatch method answers Query for every point, result k holding the intervals overlapping points[k] in Query
+// order. Points are sorted once and split at the center of every node, so each node is descended into once per
+// batch rather than once per point.
+func (tree *intervalTree[T, D]) QueryBatch(points []T) [][]Interval[T, D] {
+	result := make([][]Interval[T, D], len(points))
+	order := make([]int, len(points))
+	for k := range order {
+		order[k] = k
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return points[order[i]] < points[order[j]]
+	})
+	tree.queryBatch(points, order, result)
+	return result
+}
+
+// queryBatch method is a technical method used inside QueryBatch, order holds indices of points in ascending order
+// of the points.
+func (tree *intervalTree[T, D]) queryBatch(points []T, order []int, result [][]Interval[T, D]) {
+	if len(order) == 0 || tree.singleInterval == nil {
+		return
+	} else if !tree.singleInterval.blocked {
+		single := tree.singleInterval
+		for _, k := range order {
+			if single.start <= points[k] && !tree.endsBefore(single.end, points[k]) {
+				result[k] = append(result[k], Interval[T, D]{start: single.start, end: single.end, data: single.data})
+			}
+		}
+		return
+	} else if tree.flat() {
+		for _, k := range order {
+			for _, element := range tree.midSortedByStart {
+				if element.start > points[k] {
+					break
+				}
+				if !tree.endsBefore(element.end, points[k]) {
+					result[k] = append(result[k], Interval[T, D]{start: element.start, end: element.end, data: element.data})
+				}
+			}
+		}
+		return
+	}
+	split := sort.Search(len(order), func(k int) bool {
+		return points[order[k]] >= tree.center
+	})
+	if tree.leftSubtree != nil {
+		tree.leftSubtree.queryBatch(points, order[:split], result)
+	}
+	for _, k := range order[:split] {
+		for _, element := range tree.midSortedByStart {
+			if element.start > points[k] {
+				break
+			}
+			result[k] = append(result[k], Interval[T, D]{start: element.start, end: element.end, data: element.data})
+		}
+	}
+	for _, k := range order[split:] {
+		for _, element := range tree.midSortedByEnd {
+			if tree.endsBefore(element.end, points[k]) {
+				break
+			}
+			result[k] = append(result[k], Interval[T, D]{start: element.start, end: element.end, data: element.data})
+		}
+	}
+	if tree.rightSubtree != nil {
+		tree.rightSubtree.queryBatch(points, order[split:], result)
+	}
+}
+
+// QueryWhere method returns all intervals overlapping x whose data satisfies pred in Query order, i.e. Query(x)
+// filtered by pred, testing intervals during the walk so that rejected intervals are never collected.
+func (tree *intervalTree[T, D]) QueryWhere(x T, pred func(data D) bool) []Interval[T, D] {
+	var result []Interval[T, D]
+	tree.visitQuery(x, func(i *interval[T, D]) bool {
+		if pred(i.data) {
+			result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+		}
+		return true
+	})
+	return result
+}
+
+// FindFirstOverlap method returns the interval overlapping x with the smallest start, ties broken by Query order,
+// and ok is false if no interval overlaps x. Only the first stabbed interval of a mid-list sorted by start is
+// considered, and right subtrees are skipped once an interval straddling the center overlaps x, since intervals
+// there start after the center.
+func (tree *intervalTree[T, D]) FindFirstOverlap(x T) (result Interval[T, D], ok bool) {
+	first := tree.firstOverlap(x)
+	if first == nil {
+		return result, false
+	}
+	return Interval[T, D]{start: first.start, end: first.end, data: first.data}, true
+}
+
+// firstOverlap method is a technical method used inside FindFirstOverlap.
+func (tree *intervalTree[T, D]) firstOverlap(x T) *interval[T, D] {
+	if tree.singleInterval == nil {
+		return nil
+	} else if !tree.singleInterval.blocked {
+		if tree.singleInterval.start <= x && !tree.endsBefore(tree.singleInterval.end, x) {
+			return tree.singleInterval
+		}
+		return nil
+	} else if tree.flat() {
+		for _, element := range tree.midSortedByStart {
+			if element.start > x {
+				break
+			}
+			if !tree.endsBefore(element.end, x) {
+				return element
+			}
+		}
+		return nil
+	} else if x < tree.center {
+		var best *interval[T, D]
+		if tree.leftSubtree != nil {
+			best = tree.leftSubtree.firstOverlap(x)
+		}
+		if len(tree.midSortedByStart) > 0 {
+			if element := tree.midSortedByStart[0]; element.start <= x && (best == nil || element.start < best.start) {
+				best = element
+			}
+		}
+		return best
+	}
+	var best *interval[T, D]
+	for _, element := range tree.midSortedByEnd {
+		if tree.endsBefore(element.end, x) {
+			break
+		}
+		if best == nil || element.start < best.start {
+			best = element
+		}
+	}
+	if best == nil && tree.rightSubtree != nil {
+		return tree.rightSubtree.firstOverlap(x)
+	}
+	return best
+}
+
+// distanceTo method returns the distance from x to the interval, zero if the interval contains x and the gap
+// between x and the nearest interval edge otherwise.
+func (i *interval[T, D]) distanceTo(x T) T {
+	if x < i.start {
+		return i.start - x
+	} else if x >= i.end {
+		return x - i.end
+	}
+	return 0
+}
+
+// KNearest method returns up to k intervals closest to x ordered by distance, then by start and then by end.
+// The distance is zero for intervals containing x and the gap between x and the nearest interval edge otherwise.
+// Subtrees whose intervals cannot be closer to x than the k-th best interval found so far are pruned: intervals of
+// a left subtree end at or before its center and intervals of a right subtree start after it.
+func (tree *intervalTree[T, D]) KNearest(x T, k int) []Interval[T, D] {
+	if k <= 0 {
+		return nil
+	}
+	best := make([]*interval[T, D], 0, k)
+	tree.nearest(x, k, &best)
+	result := make([]Interval[T, D], 0, len(best))
+	for _, i := range best {
+		result = append(result, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+	}
+	return result
+}
+
+// nearest method is a technical method used inside KNearest, best holds up to k intervals found so far in result
+// order.
+func (tree *intervalTree[T, D]) nearest(x T, k int, best *[]*interval[T, D]) {
+	if tree.singleInterval == nil {
+		return
+	}
+	closer := func(a, b *interval[T, D]) bool {
+		if da, db := a.distanceTo(x), b.distanceTo(x); da != db {
+			return da < db
+		}
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		return a.end < b.end
+	}
+	offer := func(i *interval[T, D]) {
+		if len(*best) == k && !closer(i, (*best)[k-1]) {
+			return
+		}
+		position := sort.Search(len(*best), func(n int) bool {
+			return closer(i, (*best)[n])
+		})
+		if len(*best) == k {
+			*best = (*best)[:k-1]
+		}
+		*best = slices.Insert(*best, position, i)
+	}
+	// reachable reports whether a subtree whose intervals are at least bound away from x may improve the result
+	reachable := func(bound T) bool {
+		return len(*best) < k || bound <= (*best)[k-1].distanceTo(x)
+	}
+	if !tree.singleInterval.blocked {
+		offer(tree.singleInterval)
+		return
+	}
+	for _, i := range tree.midSortedByStart {
+		offer(i)
+	}
+	var leftBound, rightBound T
+	if x > tree.center {
+		leftBound = x - tree.center
+	}
+	if x < tree.center {
+		rightBound = tree.center - x
+	}
+	first, second, firstBound, secondBound := tree.leftSubtree, tree.rightSubtree, leftBound, rightBound
+	if x >= tree.center {
+		first, second, firstBound, secondBound = second, first, secondBound, firstBound
+	}
+	if first != nil && reachable(firstBound) {
+		first.nearest(x, k, best)
+	}
+	if second != nil && reachable(secondBound) {
+		second.nearest(x, k, best)
+	}
+}
+
+// NearestInterval method returns the interval closest to x as defined by KNearest, ties broken by start and then
+// by end, and ok is false for an empty tree. Subtrees which cannot hold an interval closer than the best one found
+// so far are pruned.
+func (tree *intervalTree[T, D]) NearestInterval(x T) (result Interval[T, D], ok bool) {
+	nearest := tree.KNearest(x, 1)
+	if len(nearest) == 0 {
+		return result, false
+	}
+	return nearest[0], true
+}
+
+// ForEach method calls fn for every interval maintained in the tree in ascending order of start and then of end and
+// stops as soon as fn returns false. Intervals of a right subtree start after the center, so only the left subtree
+// has to be merged with the mid-list of a node while walking, no slice of all intervals is built. As Query,
+// it relies on the tree being sorted.
+func (tree *intervalTree[T, D]) ForEach(fn func(Interval[T, D]) bool) {
+	tree.visitInOrder(func(i *interval[T, D]) bool {
+		return fn(Interval[T, D]{start: i.start, end: i.end, data: i.data})
+	})
+}
+
+// visitInOrder method is a technical method used inside ForEach.
+func (tree *intervalTree[T, D]) visitInOrder(fn func(i *interval[T, D]) bool) bool {
+	if tree.singleInterval == nil {
+		return true
+	} else if !tree.singleInterval.blocked {
+		return fn(tree.singleInterval)
+	}
+	mid, k := tree.midSortedByStart, 0
+	if tree.leftSubtree != nil && !tree.leftSubtree.visitInOrder(func(i *interval[T, D]) bool {
+		for ; k < len(mid) && (mid[k].start < i.start || (mid[k].start == i.start && mid[k].end < i.end)); k++ {
+			if !fn(mid[k]) {
+				return false
+			}
+		}
+		return fn(i)
+	}) {
+		return false
+	}
+	for ; k < len(mid); k++ {
+		if !fn(mid[k]) {
+			return false
+		}
+	}
+	if tree.rightSubtree != nil {
+		return tree.rightSubtree.visitInOrder(fn)
+	}
+	return true
+}
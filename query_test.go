@@ -0,0 +1,924 @@
+package gointervaltree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// Tests
+
+func TestIntervalTree_QueryCentroid(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(0, 10, nil)
+	_ = tree.AddInterval(2, 4, nil)
+	_ = tree.AddInterval(50, 60, nil)
+	tree.Sort()
+	centroid, ok := tree.QueryCentroid(3)
+	assert.True(t, ok)
+	assert.InDelta(t, (10*5.0+2*3.0)/12, centroid, 1e-9)
+	centroid, ok = tree.QueryCentroid(55)
+	assert.True(t, ok)
+	assert.InDelta(t, 55.0, centroid, 1e-9)
+	_, ok = tree.QueryCentroid(30)
+	assert.False(t, ok)
+}
+
+func TestIntervalTree_QueryCentroidLargeCoordinates(t *testing.T) {
+	// start + end of these intervals does not fit into int8
+	tree, _ := NewIntervalTree(int8(-128), int8(0))
+	_ = tree.AddInterval(-100, -60, nil)
+	_ = tree.AddInterval(-80, -62, nil)
+	tree.Sort()
+	centroid, ok := tree.QueryCentroid(-75)
+	assert.True(t, ok)
+	assert.InDelta(t, (40*-80.0+18*-71.0)/58, centroid, 1e-9)
+}
+
+func TestIntervalTree_QuerySortedSeq(t *testing.T) {
+	intervals := [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}, {1, 99}, {40, 60}}
+	tree, _ := NewIntervalTree(0, 100)
+	for _, i := range intervals {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	for x := -1; x <= 100; x++ {
+		var observed []Interval[int, any]
+		for element := range tree.QuerySortedSeq(x) {
+			observed = append(observed, element)
+		}
+		expected := tree.Query(x)
+		sort.SliceStable(expected, func(i, j int) bool {
+			return expected[i].start < expected[j].start
+		})
+		assert.Len(t, observed, len(expected))
+		for i := range observed {
+			assert.Equal(t, expected[i].start, observed[i].start)
+		}
+	}
+}
+
+func TestIntervalTree_QuerySortedSeqEarlyBreak(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(45, 55, "b")
+	_ = tree.AddInterval(40, 60, "a")
+	_ = tree.AddInterval(48, 50, "c")
+	_ = tree.AddInterval(49, 52, "d")
+	tree.Sort()
+	var observed []Interval[int, any]
+	for element := range tree.QuerySortedSeq(49) {
+		observed = append(observed, element)
+		if len(observed) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []Interval[int, any]{{40, 60, "a"}, {45, 55, "b"}}, observed)
+}
+
+func TestIntervalTree_QuerySortedSeqSingleInterval(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	for range tree.QuerySortedSeq(5) {
+		t.Fatal("empty tree must not yield intervals")
+	}
+	_ = tree.AddInterval(1, 10, nil)
+	var observed []Interval[int, any]
+	for element := range tree.QuerySortedSeq(5) {
+		observed = append(observed, element)
+	}
+	assert.Equal(t, []Interval[int, any]{{1, 10, nil}}, observed)
+}
+
+func TestIntervalTree_QueryableRange(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	min, max := tree.QueryableRange()
+	assert.Equal(t, 0, min)
+	assert.Equal(t, 100, max)
+	intervals := [][]int{{90, 120}}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		start := random.Intn(99)
+		intervals = append(intervals, []int{start, start + 1 + random.Intn(100-start)})
+	}
+	for _, i := range intervals {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	min, max = tree.QueryableRange()
+	assert.Equal(t, 0, min)
+	assert.Equal(t, 120, max)
+	for x := min; x < max; x++ {
+		expected := 0
+		for _, i := range intervals {
+			if i[0] <= x && x < i[1] {
+				expected++
+			}
+		}
+		assert.Len(t, tree.Query(x), expected)
+	}
+}
+
+func TestIntervalTree_QueryableRangeOutOfBounds(t *testing.T) {
+	check := func(tree *IntervalTree[int, any], intervals [][]int) {
+		for _, i := range intervals {
+			assert.NoError(t, tree.AddInterval(i[0], i[1], nil))
+		}
+		tree.Sort()
+		assert.NoError(t, tree.Validate())
+		min, max := tree.QueryableRange()
+		for x := min - 5; x < max+5; x++ {
+			expected := 0
+			for _, i := range intervals {
+				if i[0] <= x && x < i[1] {
+					expected++
+				}
+			}
+			assert.Len(t, tree.Query(x), expected, "x=%d", x)
+		}
+	}
+	above, _ := NewIntervalTree(0, 1000)
+	check(above, [][]int{{1005, 1010}, {1020, 1030}, {999, 1001}, {500, 2000}, {990, 995}})
+	assert.Equal(t, 11, above.Height())
+	below, _ := NewIntervalTree(-1000, -999)
+	check(below, [][]int{{-1010, -1005}, {-1020, -1015}, {-999, -990}})
+	narrow, _ := NewIntervalTree(0, 2)
+	check(narrow, [][]int{{-10, -5}, {-20, -15}, {5, 10}, {20, 30}, {0, 1}, {1, 2}})
+
+	floats, _ := NewIntervalTree(1.0, math.Nextafter(1, 2))
+	_ = floats.AddInterval(3, 4, nil)
+	_ = floats.AddInterval(5, 6, nil)
+	_ = floats.AddInterval(-1, 0, nil)
+	floats.Sort()
+	assert.Len(t, floats.Query(3.5), 1)
+	assert.Len(t, floats.Query(5.5), 1)
+	assert.Len(t, floats.Query(-0.5), 1)
+}
+
+func TestIntervalTree_QueryFromAndUntil(t *testing.T) {
+	intervals := [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}, {90, 100}}
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.QueryFrom(10))
+	assert.Empty(t, tree.QueryUntil(10))
+	_ = tree.AddInterval(10, 20, nil)
+	assert.Len(t, tree.QueryFrom(19), 1)
+	assert.Empty(t, tree.QueryFrom(20))
+	assert.Len(t, tree.QueryUntil(11), 1)
+	assert.Empty(t, tree.QueryUntil(10))
+	for _, i := range intervals[1:] {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	for x := -1; x <= 101; x++ {
+		var expectedFrom, expectedUntil []Interval[int, any]
+		for _, i := range intervals {
+			if x < i[1] {
+				expectedFrom = append(expectedFrom, Interval[int, any]{i[0], i[1], nil})
+			}
+			if i[0] < x {
+				expectedUntil = append(expectedUntil, Interval[int, any]{i[0], i[1], nil})
+			}
+		}
+		assert.ElementsMatch(t, expectedFrom, tree.QueryFrom(x), "QueryFrom(%d)", x)
+		assert.ElementsMatch(t, expectedUntil, tree.QueryUntil(x), "QueryUntil(%d)", x)
+	}
+	assert.Contains(t, tree.QueryFrom(95), Interval[int, any]{90, 100, nil})
+	assert.NotContains(t, tree.QueryFrom(40), Interval[int, any]{30, 40, nil})
+}
+
+func TestIntervalTree_WeightedDepth(t *testing.T) {
+	weight := func(data any) float64 {
+		return data.(float64)
+	}
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, 0.0, tree.WeightedDepth(15, weight))
+	_ = tree.AddInterval(10, 20, 2.0)
+	_ = tree.AddInterval(15, 30, 3.0)
+	_ = tree.AddInterval(50, 60, 0.5)
+	tree.Sort()
+	assert.Equal(t, 5.0, tree.WeightedDepth(17, weight))
+	assert.Equal(t, 2.0, tree.WeightedDepth(12, weight))
+	assert.Equal(t, 0.5, tree.WeightedDepth(50, weight))
+	assert.Equal(t, 0.0, tree.WeightedDepth(40, weight))
+}
+
+func TestIntervalTree_QueryCollapsed(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.QueryCollapsed(15))
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(5, 30, "c")
+	_ = tree.AddInterval(10, 20, "b")
+	_ = tree.AddInterval(40, 50, "d")
+	tree.Sort()
+	collapsed := tree.QueryCollapsed(15)
+	assert.Len(t, collapsed, 2)
+	assert.Equal(t, 5, collapsed[0].Start)
+	assert.Equal(t, 30, collapsed[0].End)
+	assert.Equal(t, []any{"c"}, collapsed[0].Data)
+	assert.Equal(t, 10, collapsed[1].Start)
+	assert.Equal(t, 20, collapsed[1].End)
+	assert.ElementsMatch(t, []any{"a", "b"}, collapsed[1].Data)
+}
+
+func TestIntervalTree_QueryInnermost(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	_, ok := tree.QueryInnermost(50)
+	assert.False(t, ok)
+	_ = tree.AddInterval(100, 900, "gene")
+	_ = tree.AddInterval(200, 500, "transcript")
+	_ = tree.AddInterval(300, 350, "exon")
+	_ = tree.AddInterval(340, 390, "exon2")
+	tree.Sort()
+	innermost, ok := tree.QueryInnermost(320)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int, any]{300, 350, "exon"}, innermost)
+	innermost, _ = tree.QueryInnermost(345)
+	assert.Equal(t, Interval[int, any]{340, 390, "exon2"}, innermost)
+	innermost, _ = tree.QueryInnermost(600)
+	assert.Equal(t, Interval[int, any]{100, 900, "gene"}, innermost)
+	_, ok = tree.QueryInnermost(950)
+	assert.False(t, ok)
+
+	// lengths near the limits of T must not overflow
+	narrow, _ := NewIntervalTree[int8](-128, 127)
+	_ = narrow.AddInterval(-100, 100, "wide")
+	_ = narrow.AddInterval(-1, 1, "narrow")
+	_ = narrow.AddInterval(-128, 127, "widest")
+	narrow.Sort()
+	innermostNarrow, ok := narrow.QueryInnermost(0)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int8, any]{-1, 1, "narrow"}, innermostNarrow)
+	innermostNarrow, _ = narrow.QueryInnermost(-110)
+	assert.Equal(t, Interval[int8, any]{-128, 127, "widest"}, innermostNarrow)
+}
+
+func TestIntervalTree_QueryOutermost(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	_, ok := tree.QueryOutermost(50)
+	assert.False(t, ok)
+	_ = tree.AddInterval(200, 500, "transcript")
+	_ = tree.AddInterval(100, 900, "gene")
+	_ = tree.AddInterval(300, 350, "exon")
+	_ = tree.AddInterval(850, 950, "a")
+	_ = tree.AddInterval(880, 980, "b")
+	tree.Sort()
+	outermost, ok := tree.QueryOutermost(320)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int, any]{100, 900, "gene"}, outermost)
+	outermost, _ = tree.QueryOutermost(920)
+	assert.Equal(t, Interval[int, any]{850, 950, "a"}, outermost)
+	_, ok = tree.QueryOutermost(990)
+	assert.False(t, ok)
+
+	// lengths near the limits of T must not overflow
+	narrow, _ := NewIntervalTree[int8](-128, 127)
+	_ = narrow.AddInterval(-1, 1, "narrow")
+	_ = narrow.AddInterval(-100, 100, "wide")
+	_ = narrow.AddInterval(-128, 126, "a")
+	_ = narrow.AddInterval(-127, 127, "b")
+	narrow.Sort()
+	outermostNarrow, ok := narrow.QueryOutermost(0)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int8, any]{-128, 126, "a"}, outermostNarrow)
+	outermostNarrow, _ = narrow.QueryOutermost(126)
+	assert.Equal(t, Interval[int8, any]{-127, 127, "b"}, outermostNarrow)
+}
+
+func TestIntervalTree_QueryBin(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.QueryBin(5))
+	for _, i := range [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	for x := -1; x <= 101; x++ {
+		assert.ElementsMatch(t, tree.Query(x), tree.QueryBin(x), "point %d", x)
+	}
+	small, _ := NewIntervalTree(int8(0), int8(127))
+	_ = small.AddInterval(100, 127, nil)
+	assert.Empty(t, small.QueryBin(127))
+	assert.Len(t, small.QueryBin(126), 1)
+}
+
+func TestIntervalTree_Transition(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_ = tree.AddInterval(10, 21, "ending")
+	_ = tree.AddInterval(21, 30, "starting")
+	_ = tree.AddInterval(5, 50, "spanning")
+	tree.Sort()
+	gained, lost := tree.Transition(20)
+	assert.Equal(t, []Interval[int, any]{{21, 30, "starting"}}, gained)
+	assert.Equal(t, []Interval[int, any]{{10, 21, "ending"}}, lost)
+	gained, lost = tree.Transition(25)
+	assert.Empty(t, gained)
+	assert.Empty(t, lost)
+	gained, lost = tree.Transition(4)
+	assert.Equal(t, []Interval[int, any]{{5, 50, "spanning"}}, gained)
+	assert.Empty(t, lost)
+	for x := 0; x < 100; x++ {
+		gained, lost = tree.Transition(x)
+		assert.Equal(t, len(tree.Query(x+1))-len(tree.Query(x)), len(gained)-len(lost))
+	}
+	small, _ := NewIntervalTree(int8(0), int8(100))
+	_ = small.AddInterval(100, 127, nil)
+	smallGained, smallLost := small.Transition(127)
+	assert.Empty(t, smallGained)
+	assert.Empty(t, smallLost)
+
+	closed, _ := NewIntervalTreeWithOptions(0, 100, WithClosedIntervals())
+	_ = closed.AddInterval(0, 5, "a")
+	_ = closed.AddInterval(5, 10, "b")
+	_ = closed.AddInterval(7, 7, "point")
+	closed.Sort()
+	gained, lost = closed.Transition(4)
+	assert.Equal(t, []Interval[int, any]{{5, 10, "b"}}, gained)
+	assert.Empty(t, lost)
+	gained, lost = closed.Transition(5)
+	assert.Empty(t, gained)
+	assert.Equal(t, []Interval[int, any]{{0, 5, "a"}}, lost)
+	gained, lost = closed.Transition(6)
+	assert.Equal(t, []Interval[int, any]{{7, 7, "point"}}, gained)
+	assert.Empty(t, lost)
+	gained, lost = closed.Transition(7)
+	assert.Empty(t, gained)
+	assert.Equal(t, []Interval[int, any]{{7, 7, "point"}}, lost)
+	for x := -2; x < 12; x++ {
+		gained, lost = closed.Transition(x)
+		assert.Equal(t, len(closed.Query(x+1))-len(closed.Query(x)), len(gained)-len(lost), "x=%d", x)
+	}
+}
+
+func TestIntervalTree_QueryDistinct(t *testing.T) {
+	key := func(data any) string {
+		return data.(string)
+	}
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.QueryDistinct(15, key))
+	_ = tree.AddInterval(10, 20, "geneA")
+	_ = tree.AddInterval(12, 30, "geneA")
+	_ = tree.AddInterval(14, 16, "geneB")
+	_ = tree.AddInterval(40, 50, "geneA")
+	tree.Sort()
+	assert.Equal(t, []Interval[int, any]{{12, 30, "geneA"}, {14, 16, "geneB"}}, tree.QueryDistinct(15, key))
+	assert.Equal(t, []Interval[int, any]{{40, 50, "geneA"}}, tree.QueryDistinct(45, key))
+
+	// lengths near the limits of T must not overflow
+	narrow, _ := NewIntervalTree[int8](-128, 127)
+	_ = narrow.AddInterval(-1, 1, "geneA")
+	_ = narrow.AddInterval(-100, 100, "geneA")
+	narrow.Sort()
+	assert.Equal(t, []Interval[int8, any]{{-100, 100, "geneA"}}, narrow.QueryDistinct(0, key))
+}
+
+func TestIntervalTree_QueryEpsilon(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.QueryEpsilon(10, 0.5))
+	_ = tree.AddInterval(10, 20, "a")
+	assert.Equal(t, []Interval[int, any]{{10, 20, "a"}}, tree.QueryEpsilon(9.9999999, 1e-6))
+	_ = tree.AddInterval(20, 30, "b")
+	_ = tree.AddInterval(60, 70, "c")
+	tree.Sort()
+	assert.Equal(t, []Interval[int, any]{{10, 20, "a"}}, tree.QueryEpsilon(9.9999999, 1e-6))
+	assert.Empty(t, tree.QueryEpsilon(9.9999999, 1e-9))
+	assert.Equal(t, []Interval[int, any]{{60, 70, "c"}}, tree.QueryEpsilon(70.0000001, 1e-6))
+	assert.ElementsMatch(t, []Interval[int, any]{{10, 20, "a"}, {20, 30, "b"}}, tree.QueryEpsilon(19.9999999, 1e-6))
+	for x := -1; x <= 101; x++ {
+		assert.ElementsMatch(t, tree.Query(x), tree.QueryEpsilon(float64(x), 0), "point %d", x)
+	}
+}
+
+func TestIntervalTree_QueryRange(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	result, err := tree.QueryRange(0, 1000)
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+	random := rand.New(rand.NewSource(1))
+	var intervals []Interval[int, any]
+	for i := 0; i < 500; i++ {
+		start := random.Intn(1000)
+		intervals = append(intervals, Interval[int, any]{start, start + 1 + random.Intn(100), i})
+		_ = tree.AddInterval(intervals[i].start, intervals[i].end, i)
+		if i == 0 {
+			result, _ = tree.QueryRange(intervals[0].start, intervals[0].start+1)
+			assert.Equal(t, intervals, result)
+		}
+	}
+	tree.Sort()
+	for k := 0; k < 1000; k++ {
+		start := random.Intn(1200) - 100
+		end := start + 1 + random.Intn(150)
+		var expected []Interval[int, any]
+		for _, i := range intervals {
+			if i.start < end && start < i.end {
+				expected = append(expected, i)
+			}
+		}
+		result, err = tree.QueryRange(start, end)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, expected, result, "range [%d, %d)", start, end)
+	}
+	_, err = tree.QueryRange(10, 10)
+	assert.EqualError(t, err, "query start must be numerically less than its end")
+}
+
+func TestIntervalTree_QueryCount(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, 0, tree.QueryCount(5))
+	_ = tree.AddInterval(1, 10, nil)
+	assert.Equal(t, 1, tree.QueryCount(5))
+	assert.Equal(t, 0, tree.QueryCount(10))
+	for _, i := range [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	for x := -1; x <= 101; x++ {
+		assert.Equal(t, len(tree.Query(x)), tree.QueryCount(x), "point %d", x)
+	}
+}
+
+func TestIntervalTree_QueryWithPosition(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.QueryWithPosition(10))
+	_ = tree.AddInterval(10, 20, "a")
+	_ = tree.AddInterval(0, 60, "b")
+	tree.Sort()
+	positions := tree.QueryWithPosition(15)
+	assert.Len(t, positions, 2)
+	for _, p := range positions {
+		switch p.Interval.Data() {
+		case "a":
+			assert.Equal(t, 0.5, p.FractionBefore)
+		case "b":
+			assert.Equal(t, 0.25, p.FractionBefore)
+		}
+	}
+	positions = tree.QueryWithPosition(0)
+	assert.Len(t, positions, 1)
+	assert.Equal(t, Interval[int, any]{0, 60, "b"}, positions[0].Interval)
+	assert.Equal(t, 0.0, positions[0].FractionBefore)
+}
+
+func TestIntervalTree_QueryFunc(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	for _, i := range [][]int{{1, 10}, {10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {5, 95}} {
+		_ = tree.AddInterval(i[0], i[1], i[0]*100+i[1])
+	}
+	tree.Sort()
+	for x := -1; x <= 101; x++ {
+		var visited []Interval[int, any]
+		tree.QueryFunc(x, func(start, end int, data any) bool {
+			visited = append(visited, Interval[int, any]{start, end, data})
+			return true
+		})
+		assert.Equal(t, tree.Query(x), visited, "point %d", x)
+	}
+	calls := 0
+	tree.QueryFunc(50, func(start, end int, data any) bool {
+		calls++
+		return false
+	})
+	assert.Equal(t, 1, calls)
+	calls = 0
+	tree.QueryFunc(50, func(start, end int, data any) bool {
+		calls++
+		return calls < 2
+	})
+	assert.Equal(t, 2, calls)
+}
+
+func TestIntervalTree_AllAndOverlapping(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	for range tree.All() {
+		assert.Fail(t, "empty tree must yield nothing")
+	}
+	for _, i := range [][]int{{1, 10}, {10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {5, 95}} {
+		_ = tree.AddInterval(i[0], i[1], i[0])
+	}
+	tree.Sort()
+	var all []Interval[int, any]
+	for i := range tree.All() {
+		all = append(all, i)
+	}
+	assert.Equal(t, tree.Iter(), all)
+	for x := -1; x <= 101; x++ {
+		var overlapping []Interval[int, any]
+		for i := range tree.Overlapping(x) {
+			overlapping = append(overlapping, i)
+		}
+		assert.Equal(t, tree.Query(x), overlapping, "point %d", x)
+	}
+}
+
+func TestIntervalTree_AllAndOverlappingEarlyBreak(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	for _, i := range [][]int{{1, 10}, {10, 20}, {20, 30}, {45, 55}, {45, 56}, {46, 57}, {60, 70}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	visited := 0
+	tree.visitAll(func(i *interval[int, any]) bool {
+		visited++
+		return false
+	})
+	assert.Equal(t, 1, visited)
+	var observed []Interval[int, any]
+	for i := range tree.All() {
+		observed = append(observed, i)
+		break
+	}
+	assert.Equal(t, tree.Iter()[:1], observed)
+	observed = nil
+	for i := range tree.Overlapping(50) {
+		observed = append(observed, i)
+		break
+	}
+	assert.Equal(t, tree.Query(50)[:1], observed)
+}
+
+func TestIntervalTree_QueryContaining(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	assert.Empty(t, tree.QueryContaining(10, 20))
+	random := rand.New(rand.NewSource(1))
+	var intervals []Interval[int, any]
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		end := start + 1 + random.Intn(min(200, 1000-start))
+		intervals = append(intervals, Interval[int, any]{start, end, i})
+		_ = tree.AddInterval(start, end, i)
+	}
+	tree.Sort()
+	queries := [][]int{{0, 1000}, {999, 1000}, {0, 1}, {intervals[0].start, intervals[0].end}, {intervals[1].start, intervals[1].end}}
+	for i := 0; i < 200; i++ {
+		start := random.Intn(999)
+		queries = append(queries, []int{start, start + 1 + random.Intn(min(50, 1000-start-1))})
+	}
+	for _, q := range queries {
+		var expected []Interval[int, any]
+		for _, i := range intervals {
+			if i.start <= q[0] && q[1] <= i.end {
+				expected = append(expected, i)
+			}
+		}
+		assert.ElementsMatch(t, expected, tree.QueryContaining(q[0], q[1]), "span [%d, %d)", q[0], q[1])
+	}
+	assert.Contains(t, tree.QueryContaining(intervals[0].start, intervals[0].end), intervals[0])
+	assert.Empty(t, tree.QueryContaining(20, 20))
+}
+
+func TestIntervalTree_QueryContainedIn(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	assert.Empty(t, tree.QueryContainedIn(0, 1000))
+	random := rand.New(rand.NewSource(2))
+	var intervals []Interval[int, any]
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		end := start + 1 + random.Intn(min(100, 1000-start))
+		intervals = append(intervals, Interval[int, any]{start, end, i})
+		_ = tree.AddInterval(start, end, i)
+	}
+	tree.Sort()
+	assert.ElementsMatch(t, intervals, tree.QueryContainedIn(0, 1000))
+	assert.ElementsMatch(t, intervals, tree.QueryContainedIn(-10, 2000))
+	queries := [][]int{{intervals[0].start, intervals[0].end}, {500, 501}, {1000, 1100}}
+	for i := 0; i < 200; i++ {
+		start := random.Intn(999)
+		queries = append(queries, []int{start, start + 1 + random.Intn(min(300, 1000-start-1))})
+	}
+	for _, q := range queries {
+		var expected []Interval[int, any]
+		for _, i := range intervals {
+			if q[0] <= i.start && i.end <= q[1] {
+				expected = append(expected, i)
+			}
+		}
+		assert.ElementsMatch(t, expected, tree.QueryContainedIn(q[0], q[1]), "span [%d, %d)", q[0], q[1])
+	}
+	assert.Empty(t, tree.QueryContainedIn(1000, 1100))
+	assert.Empty(t, tree.QueryContainedIn(20, 10))
+}
+
+func TestIntervalTree_Overlaps(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.False(t, tree.Overlaps(5))
+	_ = tree.AddInterval(1, 10, nil)
+	assert.True(t, tree.Overlaps(1))
+	assert.True(t, tree.Overlaps(9))
+	assert.False(t, tree.Overlaps(10))
+	assert.False(t, tree.Overlaps(0))
+	for _, i := range [][]int{{10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	for x := -1; x <= 101; x++ {
+		assert.Equal(t, len(tree.Query(x)) > 0, tree.Overlaps(x), "point %d", x)
+	}
+}
+
+func TestIntervalTree_QueryBatch(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	assert.Equal(t, [][]Interval[int, any]{nil, nil}, tree.QueryBatch([]int{5, 1}))
+	_ = tree.AddInterval(10, 20, nil)
+	assert.Equal(t, [][]Interval[int, any]{tree.Query(15), nil}, tree.QueryBatch([]int{15, 20}))
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		start := random.Intn(990)
+		_ = tree.AddInterval(start, start+1+random.Intn(min(100, 1000-start)), i)
+	}
+	tree.Sort()
+	points := []int{-5, 1000, 500, 500, 0}
+	for i := 0; i < 300; i++ {
+		points = append(points, random.Intn(1000))
+	}
+	results := tree.QueryBatch(points)
+	assert.Len(t, results, len(points))
+	for k, x := range points {
+		assert.Equal(t, tree.Query(x), results[k], "point %d", x)
+	}
+	assert.Empty(t, tree.QueryBatch(nil))
+}
+
+func TestIntervalTree_QueryWhere(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	isString := func(data any) bool {
+		_, ok := data.(string)
+		return ok
+	}
+	assert.Empty(t, tree.QueryWhere(5, isString))
+	for k, i := range [][]int{{1, 10}, {10, 20}, {20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {5, 95}} {
+		if k%2 == 0 {
+			_ = tree.AddInterval(i[0], i[1], strconv.Itoa(k))
+		} else {
+			_ = tree.AddInterval(i[0], i[1], k)
+		}
+	}
+	tree.Sort()
+	for x := -1; x <= 101; x++ {
+		var expected []Interval[int, any]
+		for _, i := range tree.Query(x) {
+			if isString(i.Data()) {
+				expected = append(expected, i)
+			}
+		}
+		assert.Equal(t, expected, tree.QueryWhere(x, isString), "point %d", x)
+	}
+}
+
+func TestIntervalTree_FindFirstOverlap(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	_, ok := tree.FindFirstOverlap(5)
+	assert.False(t, ok)
+	_ = tree.AddInterval(10, 20, "a")
+	first, ok := tree.FindFirstOverlap(15)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int, any]{10, 20, "a"}, first)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		start := random.Intn(990)
+		_ = tree.AddInterval(start, start+1+random.Intn(min(200, 1000-start)), i)
+	}
+	tree.Sort()
+	for x := -1; x <= 1001; x++ {
+		overlapping := tree.Query(x)
+		first, ok = tree.FindFirstOverlap(x)
+		assert.Equal(t, len(overlapping) > 0, ok, "point %d", x)
+		if !ok {
+			continue
+		}
+		assert.Contains(t, overlapping, first, "point %d", x)
+		for _, i := range overlapping {
+			assert.LessOrEqual(t, first.Start(), i.Start(), "point %d", x)
+		}
+	}
+}
+
+func TestIntervalTree_KNearest(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	assert.Empty(t, tree.KNearest(10, 3))
+	random := rand.New(rand.NewSource(1))
+	var intervals []Interval[int, any]
+	for i := 0; i < 300; i++ {
+		start := random.Intn(990)
+		end := start + 1 + random.Intn(min(30, 1000-start))
+		intervals = append(intervals, Interval[int, any]{start, end, i})
+		_ = tree.AddInterval(start, end, i)
+	}
+	tree.Sort()
+	distance := func(i Interval[int, any], x int) int {
+		if x < i.start {
+			return i.start - x
+		} else if x >= i.end {
+			return x - i.end
+		}
+		return 0
+	}
+	bounds := func(intervals []Interval[int, any]) [][2]int {
+		var result [][2]int
+		for _, i := range intervals {
+			result = append(result, [2]int{i.start, i.end})
+		}
+		return result
+	}
+	for _, x := range []int{-50, 0, 17, 333, 500, 999, 1200} {
+		expected := slices.Clone(intervals)
+		sort.Slice(expected, func(a, b int) bool {
+			if da, db := distance(expected[a], x), distance(expected[b], x); da != db {
+				return da < db
+			}
+			if expected[a].start != expected[b].start {
+				return expected[a].start < expected[b].start
+			}
+			return expected[a].end < expected[b].end
+		})
+		for _, k := range []int{1, 5, 20, 300, 400} {
+			nearest := tree.KNearest(x, k)
+			assert.Len(t, nearest, min(k, len(intervals)))
+			assert.Equal(t, bounds(expected[:min(k, len(expected))]), bounds(nearest), "point %d, k %d", x, k)
+		}
+	}
+	assert.Empty(t, tree.KNearest(10, 0))
+}
+
+func TestIntervalTree_NearestInterval(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	_, ok := tree.NearestInterval(50)
+	assert.False(t, ok)
+	_ = tree.AddInterval(20, 30, "a")
+	_ = tree.AddInterval(40, 45, "b")
+	_ = tree.AddInterval(60, 80, "c")
+	_ = tree.AddInterval(62, 70, "d")
+	tree.Sort()
+	for _, c := range []struct {
+		x        int
+		expected Interval[int, any]
+	}{
+		{0, Interval[int, any]{20, 30, "a"}},
+		{-10, Interval[int, any]{20, 30, "a"}},
+		{99, Interval[int, any]{60, 80, "c"}},
+		{150, Interval[int, any]{60, 80, "c"}},
+		{34, Interval[int, any]{20, 30, "a"}},
+		{36, Interval[int, any]{40, 45, "b"}},
+		{35, Interval[int, any]{20, 30, "a"}},
+		{52, Interval[int, any]{40, 45, "b"}},
+		{55, Interval[int, any]{60, 80, "c"}},
+		{65, Interval[int, any]{60, 80, "c"}},
+		{42, Interval[int, any]{40, 45, "b"}},
+	} {
+		nearest, ok := tree.NearestInterval(c.x)
+		assert.True(t, ok)
+		assert.Equal(t, c.expected, nearest, "point %d", c.x)
+	}
+}
+
+func TestIntervalTree_ForEach(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	tree.ForEach(func(Interval[int, any]) bool {
+		assert.Fail(t, "empty tree must yield nothing")
+		return true
+	})
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		start := random.Intn(990)
+		_ = tree.AddInterval(start, start+1+random.Intn(min(300, 1000-start)), i)
+		if i == 250 {
+			tree.Sort()
+		}
+	}
+	var visited []Interval[int, any]
+	tree.ForEach(func(i Interval[int, any]) bool {
+		visited = append(visited, i)
+		return true
+	})
+	assert.ElementsMatch(t, tree.Iter(), visited)
+	for k := 1; k < len(visited); k++ {
+		previous, current := visited[k-1], visited[k]
+		assert.True(t, previous.start < current.start || (previous.start == current.start && previous.end <= current.end),
+			"%v before %v", previous, current)
+	}
+	visited = nil
+	tree.ForEach(func(i Interval[int, any]) bool {
+		visited = append(visited, i)
+		return len(visited) < 3
+	})
+	assert.Len(t, visited, 3)
+}
+
+func TestIntervalTree_QueryRangeCount(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1000)
+	count, err := tree.QueryRangeCount(0, 1000)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+	random := rand.New(rand.NewSource(3))
+	for i := 0; i < 500; i++ {
+		start := random.Intn(1000)
+		_ = tree.AddInterval(start, start+1+random.Intn(100), i)
+	}
+	tree.Sort()
+	for k := 0; k < 500; k++ {
+		start := random.Intn(1200) - 100
+		end := start + 1 + random.Intn(150)
+		result, _ := tree.QueryRange(start, end)
+		count, err = tree.QueryRangeCount(start, end)
+		assert.NoError(t, err)
+		assert.Equal(t, len(result), count, "range [%d, %d)", start, end)
+	}
+	_, err = tree.QueryRangeCount(10, 10)
+	assert.Error(t, err)
+}
+
+// Benchmarks
+
+func BenchmarkIntervalTree_QueryCount(b *testing.B) {
+	tree, _ := NewIntervalTree(0, 10000)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		start := random.Intn(10000)
+		_ = tree.AddInterval(start, start+1+random.Intn(500), nil)
+	}
+	tree.Sort()
+	b.Run("benchmark-tree-query", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = len(tree.Query(i % 10000))
+		}
+	})
+	b.Run("benchmark-tree-query-count", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = tree.QueryCount(i % 10000)
+		}
+	})
+}
+
+func BenchmarkIntervalTree_Overlaps(b *testing.B) {
+	tree, _ := NewIntervalTree(0, 10000)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		start := random.Intn(10000)
+		_ = tree.AddInterval(start, start+1+random.Intn(500), nil)
+	}
+	tree.Sort()
+	b.Run("benchmark-tree-query", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = len(tree.Query(i%10000)) > 0
+		}
+	})
+	b.Run("benchmark-tree-overlaps", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = tree.Overlaps(i % 10000)
+		}
+	})
+}
+
+func BenchmarkIntervalTree_QueryBatch(b *testing.B) {
+	tree, _ := NewIntervalTree(0, 10000)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		start := random.Intn(10000)
+		_ = tree.AddInterval(start, start+1+random.Intn(50), nil)
+	}
+	tree.Sort()
+	points := make([]int, 1000)
+	for k := range points {
+		points[k] = random.Intn(10000)
+	}
+	b.Run("benchmark-tree-query-loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			results := make([][]Interval[int, any], len(points))
+			for k, x := range points {
+				results[k] = tree.Query(x)
+			}
+		}
+	})
+	b.Run("benchmark-tree-query-batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = tree.QueryBatch(points)
+		}
+	})
+}
+
+func BenchmarkIntervalTree_QueryRangeCount(b *testing.B) {
+	tree, _ := NewIntervalTree(0, 10000)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		start := random.Intn(10000)
+		_ = tree.AddInterval(start, start+1+random.Intn(500), nil)
+	}
+	tree.Sort()
+	b.Run("benchmark-tree-query-range", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			result, _ := tree.QueryRange(i%10000, i%10000+100)
+			_ = len(result)
+		}
+	})
+	b.Run("benchmark-tree-query-range-count", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = tree.QueryRangeCount(i%10000, i%10000+100)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package gointervaltree
+
+import "sync"
+
+// SafeIntervalTree wraps an intervalTree with a sync.RWMutex so that it can be shared between goroutines: mutating
+// methods take the write lock and read methods take the read lock. The plain tree takes no locks and remains
+// the choice for single-threaded use.
+type SafeIntervalTree[T Coordinate, D any] struct {
+	mutex sync.RWMutex
+	tree  *intervalTree[T, D]
+}
+
+// NewSafeIntervalTree creates and returns a SafeIntervalTree object holding data of type D configured with opts.
+func NewSafeIntervalTree[T Coordinate, D any](min, max T, opts ...Option) (*SafeIntervalTree[T, D], error) {
+	tree, err := NewTypedIntervalTree[T, D](min, max, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SafeIntervalTree[T, D]{tree: tree}, nil
+}
+
+// AddInterval method adds an interval to the tree under the write lock, see intervalTree.AddInterval.
+func (safe *SafeIntervalTree[T, D]) AddInterval(start, end T, data D) error {
+	safe.mutex.Lock()
+	defer safe.mutex.Unlock()
+	return safe.tree.AddInterval(start, end, data)
+}
+
+// Sort method sorts intervals within the tree under the write lock, see intervalTree.Sort.
+func (safe *SafeIntervalTree[T, D]) Sort() {
+	safe.mutex.Lock()
+	defer safe.mutex.Unlock()
+	safe.tree.Sort()
+}
+
+// Query method returns all intervals in the tree which overlap given point under the read lock,
+// see intervalTree.Query.
+func (safe *SafeIntervalTree[T, D]) Query(x T) []Interval[T, D] {
+	safe.mutex.RLock()
+	defer safe.mutex.RUnlock()
+	return safe.tree.Query(x)
+}
+
+// Iter method returns a slice of all intervals maintained in the tree under the read lock, see intervalTree.Iter.
+func (safe *SafeIntervalTree[T, D]) Iter() []Interval[T, D] {
+	safe.mutex.RLock()
+	defer safe.mutex.RUnlock()
+	return safe.tree.Iter()
+}
+
+// Len represents the number of intervals maintained in the tree, it is read under the read lock.
+func (safe *SafeIntervalTree[T, D]) Len() int {
+	safe.mutex.RLock()
+	defer safe.mutex.RUnlock()
+	return safe.tree.Len()
+}
@@ -0,0 +1,56 @@
+package gointervaltree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// Tests
+
+func TestSafeIntervalTree(t *testing.T) {
+	tree, err := NewSafeIntervalTree[int, string](0, 100)
+	assert.NoError(t, err)
+	assert.NoError(t, tree.AddInterval(10, 20, "a"))
+	assert.NoError(t, tree.AddInterval(15, 60, "b"))
+	assert.EqualError(t, tree.AddInterval(20, 10, "c"), "interval start must be numerically less than its end")
+	tree.Sort()
+	assert.Equal(t, 2, tree.Len())
+	assert.ElementsMatch(t, []Interval[int, string]{{10, 20, "a"}, {15, 60, "b"}}, tree.Query(17))
+	assert.ElementsMatch(t, []Interval[int, string]{{10, 20, "a"}, {15, 60, "b"}}, tree.Iter())
+	_, err = NewSafeIntervalTree[int, string](10, 0)
+	assert.EqualError(t, err, "interval tree start must be numerically less than its end")
+}
+
+func TestSafeIntervalTreeConcurrentAccess(t *testing.T) {
+	tree, _ := NewSafeIntervalTree[int, int](0, 1000)
+	for i := 0; i < 500; i++ {
+		_ = tree.AddInterval(i, i+10, i)
+	}
+	tree.Sort()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 500; i < 990; i++ {
+			_ = tree.AddInterval(i, i+10, i)
+			if i%50 == 0 {
+				tree.Sort()
+			}
+		}
+	}()
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for x := g; x < 500; x += 8 {
+				assert.Len(t, tree.Query(x), min(x, 9)+1)
+				assert.GreaterOrEqual(t, tree.Len(), 500)
+			}
+			assert.GreaterOrEqual(t, len(tree.Iter()), 500)
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, 990, tree.Len())
+	assert.Len(t, tree.Query(700), 10)
+}
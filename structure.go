@@ -0,0 +1,279 @@
+package gointervaltree
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// BFS method walks the tree level by level and returns, per level, the intervals stored at nodes of that level,
+// level 0 being the root. Nodes of a level are visited left to right, intervals of a node are sorted by start.
+func (tree *intervalTree[T, D]) BFS() [][]Interval[T, D] {
+	var result [][]Interval[T, D]
+	if tree.singleInterval == nil {
+		return result
+	}
+	for level := []*intervalTree[T, D]{tree}; len(level) > 0; {
+		var intervals []Interval[T, D]
+		var next []*intervalTree[T, D]
+		for _, node := range level {
+			if node.singleInterval == nil {
+				continue
+			} else if !node.singleInterval.blocked {
+				intervals = append(intervals, Interval[T, D]{start: node.singleInterval.start, end: node.singleInterval.end, data: node.singleInterval.data})
+				continue
+			}
+			for _, i := range node.midSortedByStart {
+				intervals = append(intervals, Interval[T, D]{start: i.start, end: i.end, data: i.data})
+			}
+			if node.leftSubtree != nil {
+				next = append(next, node.leftSubtree)
+			}
+			if node.rightSubtree != nil {
+				next = append(next, node.rightSubtree)
+			}
+		}
+		result = append(result, intervals)
+		level = next
+	}
+	return result
+}
+
+// DegenerateIntervals method returns all intervals maintained in the tree which AddInterval would reject, i.e. whose
+// end is not numerically greater than their start, or is less than their start for a WithClosedIntervals tree.
+// AddInterval never registers such intervals, so a non-empty result means the stored intervals were modified
+// afterwards, e.g. by coordinate transformations collapsing short intervals.
+func (tree *intervalTree[T, D]) DegenerateIntervals() []Interval[T, D] {
+	var result []Interval[T, D]
+	for _, element := range tree.Iter() {
+		if tree.checkBounds(element.start, element.end) != nil {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// Height method returns the number of nodes on the longest path from the root to a materialized subtree, zero for
+// an empty tree and one for a tree holding a single node. Since centers are fixed by the tree bounds, intervals
+// crowded into a small part of the bounds make the tree deep, see Rebalance.
+func (tree *intervalTree[T, D]) Height() int {
+	if tree.singleInterval == nil {
+		return 0
+	}
+	height := 0
+	if tree.leftSubtree != nil {
+		height = tree.leftSubtree.Height()
+	}
+	if tree.rightSubtree != nil {
+		height = max(height, tree.rightSubtree.Height())
+	}
+	return height + 1
+}
+
+// BalanceFactor method returns Height divided by log2 of Len, i.e. how many times longer the longest path is than
+// in a perfectly balanced binary tree of the same size. The logarithm is taken to be at least one, so trees holding
+// fewer than two intervals yield their height.
+func (tree *intervalTree[T, D]) BalanceFactor() float64 {
+	return float64(tree.Height()) / max(math.Log2(float64(tree.Len())), 1)
+}
+
+// Rebalance method rebuilds the tree with bounds shrunk to the extent of the intervals it holds, clamped to the
+// current bounds, so that centers split the actual data rather than the originally declared range, and sorts it.
+// The set of intervals and their tags are preserved. As the bounds change, so do results of methods relative to
+// the bounds, such as Gaps or BusyFraction. Trees without intervals within their bounds are left unchanged.
+func (tree *intervalTree[T, D]) Rebalance() {
+	intervals := tree.intervals()
+	if len(intervals) == 0 {
+		return
+	}
+	lower, upper := intervals[0].start, intervals[0].end
+	for _, i := range intervals {
+		lower, upper = min(lower, i.start), max(upper, i.end)
+	}
+	lower, upper = max(lower, tree.min), min(upper, tree.max)
+	if !(lower < upper) {
+		return
+	}
+	tree.min, tree.max, tree.center = lower, upper, midpoint(lower, upper)
+	tree.rebuild(intervals)
+}
+
+// String method renders the tree structure for debugging, one node per line indented by recursion level. Every node
+// shows its bounds and center followed by the single-interval slot or the mid-list sorted by start and then by end
+// and by the left and right subtrees. Intervals are rendered as [start, end) data with data formatted by %v.
+func (tree *intervalTree[T, D]) String() string {
+	var builder strings.Builder
+	tree.format(&builder, "root", 0)
+	return builder.String()
+}
+
+// format method is a technical method used inside String.
+func (tree *intervalTree[T, D]) format(builder *strings.Builder, label string, level int) {
+	indent := strings.Repeat("  ", level)
+	fmt.Fprintf(builder, "%s%s [%v, %v) center %v\n", indent, label, tree.min, tree.max, tree.center)
+	if tree.singleInterval == nil {
+		fmt.Fprintf(builder, "%s  empty\n", indent)
+		return
+	} else if !tree.singleInterval.blocked {
+		fmt.Fprintf(builder, "%s  single [%v, %v) %v\n", indent, tree.singleInterval.start, tree.singleInterval.end, tree.singleInterval.data)
+		return
+	}
+	mid := slices.Clone(tree.midSortedByStart)
+	sort.SliceStable(mid, func(i, j int) bool {
+		if mid[i].start != mid[j].start {
+			return mid[i].start < mid[j].start
+		}
+		return mid[i].end < mid[j].end
+	})
+	for _, i := range mid {
+		fmt.Fprintf(builder, "%s  mid [%v, %v) %v\n", indent, i.start, i.end, i.data)
+	}
+	if tree.leftSubtree != nil {
+		tree.leftSubtree.format(builder, "left", level+1)
+	}
+	if tree.rightSubtree != nil {
+		tree.rightSubtree.format(builder, "right", level+1)
+	}
+}
+
+// dotEscaper escapes text for use inside a double-quoted Graphviz string.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// ToDOT method renders the tree structure as a Graphviz digraph. Every node is labelled with its center and the
+// number of intervals in its mid-list, nodes holding an unblocked single interval are drawn as boxes labelled with
+// the interval and its data formatted by %v. Edges point to the left and right subtrees.
+func (tree *intervalTree[T, D]) ToDOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph intervaltree {\n")
+	next := 0
+	var walk func(node *intervalTree[T, D]) int
+	walk = func(node *intervalTree[T, D]) int {
+		id := next
+		next++
+		if node.singleInterval != nil && !node.singleInterval.blocked {
+			label := fmt.Sprintf("single [%v, %v) %v", node.singleInterval.start, node.singleInterval.end, node.singleInterval.data)
+			fmt.Fprintf(&builder, "  n%d [shape=box, label=\"%s\"];\n", id, dotEscaper.Replace(label))
+			return id
+		}
+		label := fmt.Sprintf("center %v\n%d intervals", node.center, len(node.midSortedByStart))
+		fmt.Fprintf(&builder, "  n%d [label=\"%s\"];\n", id, dotEscaper.Replace(label))
+		if node.leftSubtree != nil {
+			fmt.Fprintf(&builder, "  n%d -> n%d [label=\"left\"];\n", id, walk(node.leftSubtree))
+		}
+		if node.rightSubtree != nil {
+			fmt.Fprintf(&builder, "  n%d -> n%d [label=\"right\"];\n", id, walk(node.rightSubtree))
+		}
+		return id
+	}
+	walk(tree)
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// Validate method checks the structural invariants of the tree and returns an error describing the first violation
+// found, or nil for a well-formed tree. It checks that every interval is non-empty, that intervals of a left
+// subtree lie entirely before the center of every ancestor they lie to the left of and intervals of a right subtree
+// start after the center of every ancestor they lie to the right of, that subtree bounds and centers follow from
+// the bounds of their parent, that cached node sizes match the intervals held, that mid-lists hold the same
+// intervals, straddle the center unless the node is at the WithMaxDepth limit or too narrow to be split, and are
+// sorted once the tree is sorted. Intervals may lie outside the root bounds, AddInterval accepts them, so the root
+// bounds themselves are not enforced.
+func (tree *intervalTree[T, D]) Validate() error {
+	return tree.validate(tree.min, tree.max, false, false)
+}
+
+// validate method is a technical method used inside Validate, intervals of the node must start after lower if
+// hasLower is set and lie entirely before upper if hasUpper is set.
+func (tree *intervalTree[T, D]) validate(lower, upper T, hasLower, hasUpper bool) error {
+	node := fmt.Sprintf("node [%v, %v)", tree.min, tree.max)
+	if !(tree.min < tree.max) || tree.center != midpoint(tree.min, tree.max) {
+		return fmt.Errorf("%s: center %v is not the midpoint of the node bounds", node, tree.center)
+	}
+	check := func(i *interval[T, D], place string) error {
+		if tree.checkBounds(i.start, i.end) != nil {
+			return fmt.Errorf("%s: %s interval [%v, %v) is empty", node, place, i.start, i.end)
+		} else if hasLower && !(i.start > lower) {
+			return fmt.Errorf("%s: %s interval [%v, %v) must start after %v", node, place, i.start, i.end, lower)
+		} else if hasUpper && !tree.endsBefore(i.end, upper) {
+			return fmt.Errorf("%s: %s interval [%v, %v) must lie entirely before %v", node, place, i.start, i.end, upper)
+		}
+		return nil
+	}
+	size := 0
+	if tree.singleInterval != nil {
+		size = len(tree.midSortedByStart)
+		if !tree.singleInterval.blocked {
+			size = 1
+		}
+	}
+	if tree.leftSubtree != nil {
+		size += tree.leftSubtree.size
+	}
+	if tree.rightSubtree != nil {
+		size += tree.rightSubtree.size
+	}
+	if tree.size != size {
+		return fmt.Errorf("%s: cached size %d does not match %d intervals held", node, tree.size, size)
+	}
+	if tree.singleInterval == nil || !tree.singleInterval.blocked {
+		if tree.leftSubtree != nil || tree.rightSubtree != nil || len(tree.midSortedByStart) > 0 || len(tree.midSortedByEnd) > 0 {
+			return fmt.Errorf("%s: node without a blocked single interval must not hold mid-lists or subtrees", node)
+		} else if tree.singleInterval != nil {
+			return check(tree.singleInterval, "single")
+		}
+		return nil
+	}
+	if tree.flat() && (tree.leftSubtree != nil || tree.rightSubtree != nil) {
+		return fmt.Errorf("%s: flat node must not hold subtrees", node)
+	}
+	if len(tree.midSortedByStart) != len(tree.midSortedByEnd) {
+		return fmt.Errorf("%s: mid-lists hold %d and %d intervals", node, len(tree.midSortedByStart), len(tree.midSortedByEnd))
+	}
+	byEnd := make(map[*interval[T, D]]int, len(tree.midSortedByEnd))
+	for _, i := range tree.midSortedByEnd {
+		byEnd[i]++
+	}
+	for k, i := range tree.midSortedByStart {
+		if byEnd[i] == 0 {
+			return fmt.Errorf("%s: mid interval [%v, %v) is missing from the mid-list sorted by end", node, i.start, i.end)
+		}
+		byEnd[i]--
+		if err := check(i, "mid"); err != nil {
+			return err
+		}
+		if !tree.flat() && !(i.start <= tree.center && !tree.endsBefore(i.end, tree.center)) {
+			return fmt.Errorf("%s: mid interval [%v, %v) does not contain center %v", node, i.start, i.end, tree.center)
+		}
+		if tree.sorted && k > 0 {
+			previous := tree.midSortedByStart[k-1]
+			if previous.start > i.start || (previous.start == i.start && previous.end > i.end) {
+				return fmt.Errorf("%s: mid-list sorted by start has [%v, %v) before [%v, %v)", node, previous.start, previous.end, i.start, i.end)
+			}
+		}
+	}
+	for k := 1; tree.sorted && k < len(tree.midSortedByEnd); k++ {
+		previous, i := tree.midSortedByEnd[k-1], tree.midSortedByEnd[k]
+		if previous.end < i.end {
+			return fmt.Errorf("%s: mid-list sorted by end has [%v, %v) before [%v, %v)", node, previous.start, previous.end, i.start, i.end)
+		}
+	}
+	if left := tree.leftSubtree; left != nil {
+		if left.min != tree.min || left.max != tree.center {
+			return fmt.Errorf("%s: left subtree bounds [%v, %v) must be [%v, %v)", node, left.min, left.max, tree.min, tree.center)
+		}
+		if err := left.validate(lower, tree.center, hasLower, true); err != nil {
+			return err
+		}
+	}
+	if right := tree.rightSubtree; right != nil {
+		if right.min != tree.center || right.max != tree.max {
+			return fmt.Errorf("%s: right subtree bounds [%v, %v) must be [%v, %v)", node, right.min, right.max, tree.center, tree.max)
+		}
+		if err := right.validate(tree.center, upper, true, hasUpper); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package gointervaltree
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Tests
+
+func TestIntervalTree_BFS(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.BFS())
+	_ = tree.AddInterval(10, 20, nil)
+	assert.Equal(t, [][]Interval[int, any]{{{10, 20, nil}}}, tree.BFS())
+	for _, i := range [][]int{{20, 30}, {21, 31}, {30, 40}, {45, 55}, {45, 56}, {46, 57}, {55, 56}, {58, 59}, {50, 51}} {
+		_ = tree.AddInterval(i[0], i[1], nil)
+	}
+	tree.Sort()
+	levels := tree.BFS()
+	assert.Equal(t, []Interval[int, any]{{45, 55, nil}, {45, 56, nil}, {46, 57, nil}, {50, 51, nil}}, levels[0])
+	assert.Equal(t, []Interval[int, any]{{20, 30, nil}, {21, 31, nil}}, levels[1])
+	total := 0
+	for _, level := range levels {
+		total += len(level)
+	}
+	assert.Equal(t, tree.Len(), total)
+}
+
+func TestIntervalTree_DegenerateIntervals(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Empty(t, tree.DegenerateIntervals())
+	_ = tree.AddInterval(10, 11, "short")
+	_ = tree.AddInterval(20, 40, "long")
+	_ = tree.AddInterval(60, 61, "short")
+	tree.Sort()
+	assert.Empty(t, tree.DegenerateIntervals())
+	// emulate scaling every stored interval down by a factor of ten with integer rounding
+	var scale func(node *intervalTree[int, any])
+	scale = func(node *intervalTree[int, any]) {
+		if node == nil {
+			return
+		}
+		for _, i := range append(node.midSortedByStart, node.singleInterval) {
+			if i != nil {
+				i.start, i.end = i.start/10, i.end/10
+			}
+		}
+		scale(node.leftSubtree)
+		scale(node.rightSubtree)
+	}
+	scale(tree)
+	assert.ElementsMatch(t, []Interval[int, any]{{1, 1, "short"}, {6, 6, "short"}}, tree.DegenerateIntervals())
+}
+
+func TestIntervalTree_Height(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1<<20)
+	assert.Equal(t, 0, tree.Height())
+	assert.Equal(t, 0.0, tree.BalanceFactor())
+	_ = tree.AddInterval(0, 1, nil)
+	assert.Equal(t, 1, tree.Height())
+	assert.Equal(t, 1.0, tree.BalanceFactor())
+	for k := 1; k < 64; k++ {
+		_ = tree.AddInterval(k, k+1, nil)
+	}
+	tree.Sort()
+	assert.Equal(t, 21, tree.Height())
+	assert.InDelta(t, 3.5, tree.BalanceFactor(), 1e-9)
+
+	spread, _ := NewIntervalTree(0, 64)
+	for k := 0; k < 64; k++ {
+		_ = spread.AddInterval(k, k+1, nil)
+	}
+	spread.Sort()
+	assert.Equal(t, 7, spread.Height())
+	assert.Less(t, spread.BalanceFactor(), tree.BalanceFactor())
+}
+
+func TestIntervalTree_Rebalance(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 1<<20)
+	tree.Rebalance()
+	assert.Equal(t, 0, tree.Len())
+	for k := 0; k < 64; k++ {
+		_ = tree.AddInterval(k, k+3, k)
+	}
+	tree.Sort()
+	tree.TagInterval(10, 13, "a")
+	before := tree.Iter()
+	height := tree.Height()
+	tree.Rebalance()
+	assert.Less(t, tree.Height(), height)
+	assert.Equal(t, 0, tree.min)
+	assert.Equal(t, 66, tree.max)
+	assert.ElementsMatch(t, before, tree.Iter())
+	for x := -1; x < 70; x++ {
+		var expected []Interval[int, any]
+		for _, i := range before {
+			if i.start <= x && x < i.end {
+				expected = append(expected, i)
+			}
+		}
+		assert.ElementsMatch(t, expected, tree.Query(x), "point %d", x)
+	}
+	assert.Equal(t, []Interval[int, any]{{10, 13, 10}}, tree.QueryByTag("a"))
+
+	clamped, _ := NewIntervalTree(10, 100)
+	_ = clamped.AddInterval(0, 20, nil)
+	_ = clamped.AddInterval(30, 40, nil)
+	clamped.Rebalance()
+	assert.Equal(t, 10, clamped.min)
+	assert.Equal(t, 40, clamped.max)
+	assert.Len(t, clamped.Query(5), 1)
+}
+
+func TestIntervalTree_String(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, "root [0, 100) center 50\n  empty\n", tree.String())
+	_ = tree.AddInterval(10, 20, "a")
+	assert.Equal(t, "root [0, 100) center 50\n  single [10, 20) a\n", tree.String())
+	_ = tree.AddInterval(46, 57, nil)
+	_ = tree.AddInterval(45, 56, "c")
+	_ = tree.AddInterval(45, 55, "b")
+	_ = tree.AddInterval(20, 30, "d")
+	_ = tree.AddInterval(70, 80, 7)
+	tree.Sort()
+	expected := `root [0, 100) center 50
+  mid [45, 55) b
+  mid [45, 56) c
+  mid [46, 57) <nil>
+  left [0, 50) center 25
+    mid [20, 30) d
+    left [0, 25) center 12
+      single [10, 20) a
+  right [50, 100) center 75
+    single [70, 80) 7
+`
+	assert.Equal(t, expected, tree.String())
+	assert.Equal(t, expected, fmt.Sprint(tree))
+}
+
+func TestIntervalTree_ToDOT(t *testing.T) {
+	tree, _ := NewIntervalTree(0, 100)
+	assert.Equal(t, "digraph intervaltree {\n  n0 [label=\"center 50\\n0 intervals\"];\n}\n", tree.ToDOT())
+	_ = tree.AddInterval(10, 20, `say "hi"\`)
+	_ = tree.AddInterval(45, 55, "b")
+	_ = tree.AddInterval(20, 30, "d")
+	_ = tree.AddInterval(70, 80, "line\nbreak")
+	tree.Sort()
+	dot := tree.ToDOT()
+	assert.True(t, strings.HasPrefix(dot, "digraph intervaltree {\n"))
+	assert.True(t, strings.HasSuffix(dot, "}\n"))
+	nodes, edges := 0, 0
+	for _, line := range strings.Split(dot, "\n") {
+		if strings.Contains(line, "->") {
+			edges++
+		} else if strings.Contains(line, "[") {
+			nodes++
+		}
+	}
+	assert.Equal(t, 4, nodes)
+	assert.Equal(t, 3, edges)
+	assert.Contains(t, dot, `n2 [shape=box, label="single [10, 20) say \"hi\"\\"];`)
+	assert.Contains(t, dot, `n3 [shape=box, label="single [70, 80) line\nbreak"];`)
+	assert.Contains(t, dot, `n0 [label="center 50\n1 intervals"];`)
+	assert.Contains(t, dot, `n1 -> n2 [label="left"];`)
+	assert.Contains(t, dot, `n0 -> n3 [label="right"];`)
+}
+
+func TestIntervalTree_Validate(t *testing.T) {
+	build := func(opts ...Option) *IntervalTree[int, any] {
+		tree, _ := NewIntervalTreeWithOptions(0, 1000, opts...)
+		random := rand.New(rand.NewSource(4))
+		for i := 0; i < 300; i++ {
+			start := random.Intn(990)
+			_ = tree.AddInterval(start, start+1+random.Intn(min(100, 1000-start-1)), i)
+		}
+		return tree
+	}
+	assertViolation := func(tree *IntervalTree[int, any], fragment string) {
+		if err := tree.Validate(); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), fragment)
+		}
+	}
+	empty, _ := NewIntervalTree(0, 10)
+	assert.NoError(t, empty.Validate())
+	tree := build()
+	assert.NoError(t, tree.Validate())
+	tree.Sort()
+	assert.NoError(t, tree.Validate())
+	_ = tree.AddInterval(-50, 2000, nil)
+	_, _ = tree.RemoveInterval(tree.Iter()[0].start, tree.Iter()[0].end, tree.Iter()[0].data)
+	assert.NoError(t, tree.Validate())
+	flat := build(WithMaxDepth(2))
+	flat.Sort()
+	assert.NoError(t, flat.Validate())
+
+	corrupted := build()
+	corrupted.Sort()
+	corrupted.leftSubtree.midSortedByStart[0].end = corrupted.center + 1
+	assertViolation(corrupted, "must lie entirely before 500")
+
+	corrupted = build()
+	corrupted.Sort()
+	mid := corrupted.rightSubtree.midSortedByStart
+	mid[0], mid[len(mid)-1] = mid[len(mid)-1], mid[0]
+	assertViolation(corrupted, "mid-list sorted by start has")
+
+	corrupted = build()
+	corrupted.midSortedByStart[0].start = corrupted.center + 1
+	assertViolation(corrupted, "does not contain center 500")
+
+	corrupted = build()
+	corrupted.midSortedByEnd = corrupted.midSortedByEnd[1:]
+	assertViolation(corrupted, "mid-lists hold")
+
+	corrupted = build()
+	corrupted.leftSubtree.max++
+	assertViolation(corrupted, "left subtree bounds [0, 501) must be [0, 500)")
+
+	corrupted, _ = NewIntervalTree(0, 1000)
+	_ = corrupted.AddInterval(1, 2, nil)
+	corrupted.singleInterval.end = 1
+	assertViolation(corrupted, "single interval [1, 1) is empty")
+}